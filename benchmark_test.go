@@ -1,6 +1,7 @@
 package localcache_test
 
 import (
+	"strconv"
 	"sync"
 	"testing"
 
@@ -125,3 +126,147 @@ func BenchmarkLocalCache_Set(b *testing.B) {
 		localCache.Set("bar", i)
 	}
 }
+
+func benchmarkStringKeys(localCache *localcache.LocalCache) []string {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key:" + strconv.Itoa(i)
+		localCache.Set(keys[i], i)
+	}
+	return keys
+}
+
+func BenchmarkLocalCache_GetStringKey(b *testing.B) {
+	localCache := localcache.New(localcache.WithShards(16))
+	keys := benchmarkStringKeys(localCache)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		localCache.Get(keys[i&1023])
+	}
+}
+
+func BenchmarkLocalCache_GetStringKeyed(b *testing.B) {
+	localCache := localcache.New(localcache.WithShards(16))
+	keys := benchmarkStringKeys(localCache)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		localCache.GetStringKeyed(keys[i&1023])
+	}
+}
+
+func BenchmarkLocalCache_GetIntKey(b *testing.B) {
+	localCache := localcache.New(localcache.WithShards(16))
+	localCache.MSet(benchmarkItems(2048))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		localCache.Get(1024 + i&1023)
+	}
+}
+
+func BenchmarkLocalCache_GetIntKeyed(b *testing.B) {
+	localCache := localcache.New(localcache.WithShards(16))
+	localCache.MSet(benchmarkItems(2048))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		localCache.GetIntKeyed(1024 + i&1023)
+	}
+}
+
+func benchmarkLocalCacheParallel(b *testing.B, shards int) {
+	localCache := localcache.NewLocalCache(&localcache.CacheConfig{Shards: shards})
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			localCache.Set(i&1023, i)
+			localCache.Get((i + 512) & 1023)
+			i++
+		}
+	})
+}
+
+func BenchmarkLocalCache_ParallelSingleLock(b *testing.B) {
+	benchmarkLocalCacheParallel(b, 1)
+}
+
+func BenchmarkLocalCache_ParallelSharded(b *testing.B) {
+	benchmarkLocalCacheParallel(b, 32)
+}
+
+func benchmarkItems(n int) map[localcache.Key]interface{} {
+	items := make(map[localcache.Key]interface{}, n)
+	for i := 0; i < n; i++ {
+		items[i] = i
+	}
+	return items
+}
+
+func BenchmarkLocalCache_MSet10k(b *testing.B) {
+	localCache := localcache.NewLocalCache(nil)
+	items := benchmarkItems(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		localCache.MSet(items)
+	}
+}
+
+func BenchmarkLocalCache_SetLoop10k(b *testing.B) {
+	localCache := localcache.NewLocalCache(nil)
+	items := benchmarkItems(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for key, value := range items {
+			localCache.Set(key, value)
+		}
+	}
+}
+
+// benchmarkFill insert n keys into a new cache created by options.
+func benchmarkFill(b *testing.B, n int, options ...localcache.Option) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		localCache := localcache.New(options...)
+		for key := 0; key < n; key++ {
+			localCache.Set(key, key)
+		}
+		localCache.Close()
+	}
+}
+
+func BenchmarkLocalCache_Fill100k(b *testing.B) {
+	benchmarkFill(b, 100000)
+}
+
+func BenchmarkLocalCache_Fill100kInitialCapacity(b *testing.B) {
+	benchmarkFill(b, 100000, localcache.WithInitialCapacity(100000))
+}
+
+// benchmarkGetKeysEntry read batches of live keys in parallel from a cache created by options.
+func benchmarkGetKeysEntry(b *testing.B, options ...localcache.Option) {
+	localCache := localcache.New(options...)
+	defer localCache.Close()
+	keys := make([]localcache.Key, 16)
+	for i := range keys {
+		keys[i] = i
+		localCache.Set(i, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			localCache.GetKeysEntry(keys)
+		}
+	})
+}
+
+func BenchmarkLocalCache_GetKeysEntryParallel(b *testing.B) {
+	benchmarkGetKeysEntry(b, localcache.WithShards(1))
+}
+
+func BenchmarkLocalCache_GetKeysEntryParallelWriteLock(b *testing.B) {
+	benchmarkGetKeysEntry(b, localcache.WithShards(1), localcache.WithMaxEntries(1024))
+}
@@ -0,0 +1,21 @@
+package localcache
+
+import "time"
+
+// Cache is the core method set of LocalCache, code depend on it can be tested with a fake.
+type Cache interface {
+	Add(key Key, value interface{}) error
+	AddWithExpire(key Key, value interface{}, duration time.Duration) error
+	Set(key Key, value interface{})
+	SetWithExpire(key Key, value interface{}, duration time.Duration)
+	Has(key Key) bool
+	Get(key Key) (interface{}, error)
+	GetWithExpire(key Key) (interface{}, time.Duration, error)
+	Delete(key Key) bool
+	Expire(key Key) error
+	Flush()
+	Stats() *CacheStat
+	Close() error
+}
+
+var _ Cache = (*LocalCache)(nil)
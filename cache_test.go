@@ -0,0 +1,116 @@
+package localcache_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/leaxoy/localcache"
+)
+
+// fakeCache is a map based localcache.Cache without expiration.
+type fakeCache struct {
+	data  map[localcache.Key]interface{}
+	stats localcache.CacheStat
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{data: make(map[localcache.Key]interface{})}
+}
+
+func (f *fakeCache) Add(key localcache.Key, value interface{}) error {
+	return f.AddWithExpire(key, value, 0)
+}
+
+func (f *fakeCache) AddWithExpire(key localcache.Key, value interface{}, duration time.Duration) error {
+	if _, ok := f.data[key]; ok {
+		return localcache.ErrDuplicateKey
+	}
+	f.Set(key, value)
+	return nil
+}
+
+func (f *fakeCache) Set(key localcache.Key, value interface{}) {
+	f.data[key] = value
+	f.stats.Entries = int64(len(f.data))
+}
+
+func (f *fakeCache) SetWithExpire(key localcache.Key, value interface{}, duration time.Duration) {
+	f.Set(key, value)
+}
+
+func (f *fakeCache) Has(key localcache.Key) bool {
+	_, ok := f.data[key]
+	return ok
+}
+
+func (f *fakeCache) Get(key localcache.Key) (interface{}, error) {
+	v, ok := f.data[key]
+	if !ok {
+		f.stats.Misses++
+		return nil, localcache.ErrNoSuchKey
+	}
+	f.stats.Hits++
+	return v, nil
+}
+
+func (f *fakeCache) GetWithExpire(key localcache.Key) (interface{}, time.Duration, error) {
+	v, err := f.Get(key)
+	if err != nil {
+		return nil, localcache.ExpireDuration, err
+	}
+	return v, localcache.NeverExpireDuration, nil
+}
+
+func (f *fakeCache) Delete(key localcache.Key) bool {
+	ok := f.Has(key)
+	delete(f.data, key)
+	f.stats.Entries = int64(len(f.data))
+	return ok
+}
+
+func (f *fakeCache) Expire(key localcache.Key) error {
+	if !f.Delete(key) {
+		return localcache.ErrNoSuchKey
+	}
+	return nil
+}
+
+func (f *fakeCache) Flush() {
+	f.data = make(map[localcache.Key]interface{})
+	f.stats.Entries = 0
+}
+
+func (f *fakeCache) Stats() *localcache.CacheStat {
+	stats := f.stats
+	return &stats
+}
+
+func (f *fakeCache) Close() error {
+	return nil
+}
+
+var _ localcache.Cache = (*fakeCache)(nil)
+
+// greeting is a function under test which depend on localcache.Cache.
+func greeting(cache localcache.Cache, name string) string {
+	if v, err := cache.Get(name); err == nil {
+		return v.(string)
+	}
+	msg := fmt.Sprintf("hello, %s", name)
+	cache.Set(name, msg)
+	return msg
+}
+
+func TestCache_Fake(t *testing.T) {
+	for _, cache := range []localcache.Cache{newFakeCache(), localcache.NewLocalCache(nil)} {
+		greeting(cache, "foo")
+		if msg := greeting(cache, "foo"); msg != "hello, foo" {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "hello, foo", msg)
+		}
+		if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+			t.Errorf("err: unexpected stats: %+v\n", stats)
+		}
+		cache.Close()
+	}
+}
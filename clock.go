@@ -0,0 +1,15 @@
+package localcache
+
+import "time"
+
+// Clock tell the current time, a fake Clock can be injected by CacheConfig.Clock to control expiration in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the default Clock backed by time.Now.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,52 @@
+package localcache_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leaxoy/localcache"
+)
+
+// fakeClock is a localcache.Clock which only move forward by Advance.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	f.mu.Unlock()
+}
+
+func TestLocalCache_Clock(t *testing.T) {
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithClock(clock), localcache.WithExpiration(time.Hour))
+	defer localCache.Close()
+	localCache.Set("xxx", 1)
+	if ttl, _ := localCache.TTL("xxx"); ttl != time.Hour {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", time.Hour, ttl)
+	}
+	clock.Advance(59 * time.Minute)
+	if _, err := localCache.Get("xxx"); err != nil {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", nil, err)
+	}
+	if created, accessed, _, _ := localCache.EntryInfo("xxx"); accessed.Sub(created) != 59*time.Minute {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 59*time.Minute, accessed.Sub(created))
+	}
+	clock.Advance(2 * time.Minute)
+	if _, err := localCache.Get("xxx"); err != localcache.ErrExpiredKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrExpiredKey, err)
+	}
+}
@@ -0,0 +1,56 @@
+package localcache
+
+import (
+	"container/list"
+	"sync/atomic"
+)
+
+// Clone return an independent cache with the same config and a copy of live entries, but fresh stats.
+// The default expiration changed by SetDefaultExpiration is kept.
+// The clone has its own background goroutines and no evicted func, OnEvict callbacks or WriteBack sink.
+// Stored values are not deep copied, so values of reference types are shared with the original.
+func (c *LocalCache) Clone() *LocalCache {
+	config := c.config
+	config.Expiration = c.defaultExpiration()
+	config.EvictedFunc = nil
+	config.WriteBack = nil
+	clone := newLocalCache(&config)
+	for i, s := range c.shards {
+		cs := clone.shards[i]
+		s.mu.RLock()
+		cs.mu.Lock()
+		if s.order != nil {
+			for _, l := range []*list.List{s.protected, s.order} {
+				if l == nil {
+					continue
+				}
+				for elem := l.Back(); elem != nil; elem = elem.Prev() {
+					key := elem.Value.(Key)
+					cs.copyEntry(key, s.data[key])
+				}
+			}
+		} else {
+			for key, e := range s.data {
+				cs.copyEntry(key, e)
+			}
+		}
+		panics := cs.panics
+		cs.panics = nil
+		cs.mu.Unlock()
+		s.mu.RUnlock()
+		// sizer panics of the clone are reported to the handlers of c, the clone has none yet
+		if len(panics) > 0 {
+			c.reportPanics(panics)
+		}
+	}
+	return clone
+}
+
+// copyEntry store a live entry copied from another cache, the caller must hold the write lock.
+func (s *shard) copyEntry(key Key, e Entry) {
+	if e.IsExpired() {
+		return
+	}
+	meta := &entryMeta{created: e.meta.created, accessed: atomic.LoadInt64(&e.meta.accessed), clock: s.clock}
+	s.set(key, Entry{value: e.value, expire: e.expire, meta: meta, sliding: e.sliding, version: e.version, tags: e.tags})
+}
@@ -0,0 +1,38 @@
+package localcache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leaxoy/localcache"
+)
+
+func TestLocalCache_Clone(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(4), localcache.WithMaxEntries(100))
+	defer localCache.Close()
+	for i := 0; i < 10; i++ {
+		localCache.SetWithExpire(i, i, time.Minute)
+	}
+	localCache.SetWithExpire("short", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	clone := localCache.Clone()
+	defer clone.Close()
+	if stats := clone.Stats(); stats.Entries != 10 || stats.Hits != 0 {
+		t.Errorf("err: not equal, entries expect %+v, but got %+v\n", 10, stats.Entries)
+	}
+	if clone.Has("short") {
+		t.Errorf("err: expect expired key %+v not cloned\n", "short")
+	}
+	if ttl, _ := clone.TTL(1); ttl > time.Minute || ttl < time.Minute-time.Second {
+		t.Errorf("err: ttl out of range, got: %+v\n", ttl)
+	}
+	clone.Set(1, 100)
+	clone.Delete(2)
+	clone.Set("new", 1)
+	if v, _ := localCache.Get(1); v != 1 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 1, v)
+	}
+	if !localCache.Has(2) || localCache.Has("new") {
+		t.Errorf("err: original changed by clone\n")
+	}
+}
@@ -0,0 +1,86 @@
+package localcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec serialize keys and values for Save and Load.
+type Codec interface {
+	Encode(v interface{}) ([]byte, error)
+	Decode(data []byte, v *interface{}) error
+}
+
+// GobCodec is the Codec using encoding/gob, concrete types must be registered by gob.Register.
+type GobCodec struct{}
+
+// Encode encode v as interface{}, so its concrete type is kept.
+func (GobCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decode data written by Encode into v.
+func (GobCodec) Decode(data []byte, v *interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// JSONCodec is the Codec using encoding/json, values are decoded into interface{}, so numbers
+// become float64 and structs become map[string]interface{}. Keys are encoded by it as well, so only
+// string keys are restored as they were: an int key is restored as float64 and Get of the int misses.
+// Use GobCodec for caches with keys of other types.
+type JSONCodec struct{}
+
+// Encode encode v as json.
+func (JSONCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Decode decode json data into v.
+func (JSONCodec) Decode(data []byte, v *interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// codecEntry is the persistent form of an entry whose key and value are encoded by Codec.
+type codecEntry struct {
+	Key    []byte
+	Value  []byte
+	Expire int64
+}
+
+// encodeEntries encode keys and values of entries by codec.
+func encodeEntries(codec Codec, entries []snapshotEntry) ([]codecEntry, error) {
+	encoded := make([]codecEntry, 0, len(entries))
+	for _, se := range entries {
+		key, err := codec.Encode(se.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := codec.Encode(se.Value)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, codecEntry{Key: key, Value: value, Expire: se.Expire})
+	}
+	return encoded, nil
+}
+
+// decodeEntries decode keys and values of entries encoded by codec.
+func decodeEntries(codec Codec, encoded []codecEntry) ([]snapshotEntry, error) {
+	entries := make([]snapshotEntry, 0, len(encoded))
+	for _, ce := range encoded {
+		var key, value interface{}
+		if err := codec.Decode(ce.Key, &key); err != nil {
+			return nil, err
+		}
+		if err := codec.Decode(ce.Value, &value); err != nil {
+			return nil, err
+		}
+		entries = append(entries, snapshotEntry{Key: key, Value: value, Expire: ce.Expire})
+	}
+	return entries, nil
+}
@@ -0,0 +1,347 @@
+package localcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// GetOrCompute get the value associated by key, or call loader to compute it and store with ttl if
+// key not exist or has expired. Concurrent callers of the same key share one loader call,
+// errors returned by loader will not be cached, and ErrNegativeCached or the error cached by GetOrLoad
+// returned for negative cached key. A panic in loader will be propagated to all callers waiting for it.
+func (c *LocalCache) GetOrCompute(key Key, ttl time.Duration, loader func() (interface{}, error)) (interface{}, error) {
+	return c.GetOrComputeContext(context.Background(), key, ttl, func(context.Context) (interface{}, error) {
+		return loader()
+	})
+}
+
+// GetOrComputeContext will do same as GetOrCompute but respect cancellation of ctx.
+// The loader runs in the caller which starts the load and receives its ctx,
+// other callers waiting for the in-flight load return ctx.Err() once their ctx is done. If the load fails
+// because ctx of the loading caller is done, waiters whose ctx is still alive retry the load themselves.
+func (c *LocalCache) GetOrComputeContext(ctx context.Context, key Key, ttl time.Duration, loader func(context.Context) (interface{}, error)) (interface{}, error) {
+	return c.compute(ctx, key, func(ctx context.Context) (interface{}, time.Duration, error) {
+		v, err := loader(ctx)
+		return v, ttl, err
+	})
+}
+
+// compute will do same as GetOrComputeContext but the ttl of value is returned by loader.
+func (c *LocalCache) compute(ctx context.Context, key Key, loader func(context.Context) (interface{}, time.Duration, error)) (interface{}, error) {
+	for {
+		if v, err := c.Get(key); err != ErrNoSuchKey && err != ErrExpiredKey {
+			return v, err
+		}
+		cl, leader := c.flight.join(key)
+		if leader {
+			v, err := c.flight.run(key, cl, func() (interface{}, error) {
+				return c.load(ctx, key, cl, loader)
+			})
+			if err != nil {
+				return nil, err
+			}
+			return c.copyOut(v)
+		}
+		select {
+		case <-cl.done:
+			if cl.abandoned && ctx.Err() == nil {
+				continue
+			}
+			v, err := cl.result()
+			if err != nil {
+				return nil, err
+			}
+			return c.copyOut(v)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// load call loader for key and store the value as the leader of cl, cl is marked abandoned if ctx is done.
+func (c *LocalCache) load(ctx context.Context, key Key, cl *call, loader func(context.Context) (interface{}, time.Duration, error)) (interface{}, error) {
+	if e, ok := c.live(key); ok {
+		if e.isNegative() {
+			return nil, e.negativeErr()
+		}
+		return e.value, nil
+	}
+	if err := c.acquireLoad(ctx); err != nil {
+		cl.abandoned = ctx.Err() != nil
+		return nil, err
+	}
+	defer c.releaseLoad()
+	v, ttl, err := loader(ctx)
+	if err != nil {
+		cl.abandoned = ctx.Err() != nil
+		return nil, err
+	}
+	c.SetWithExpire(key, v, ttl)
+	return v, nil
+}
+
+// GetOrComputeMulti get the values associated by keys, and call loader once with all keys not exist or
+// have expired to compute them, the returned values are stored with ttl and merged into the result.
+// Keys being loaded by concurrent GetOrCompute or GetOrComputeMulti are not passed to loader, their
+// results are waited and shared instead. Keys missing from the map returned by loader, negative cached
+// keys and keys whose shared load failed, panicked or was abandoned are absent from the result. An error
+// returned by loader is returned with the values found, and will not be cached.
+func (c *LocalCache) GetOrComputeMulti(keys []Key, ttl time.Duration, loader func(missing []Key) (map[Key]interface{}, error)) (map[Key]interface{}, error) {
+	if c.isClosed() {
+		return map[Key]interface{}{}, ErrCacheClosed
+	}
+	values := make(map[Key]interface{}, len(keys))
+	var missing []Key
+	c.lookupKeys(keys, func(key Key, e Entry, ok bool) {
+		switch {
+		case !ok:
+			missing = append(missing, key)
+		case !e.isNegative():
+			values[key] = e.value
+		}
+	})
+	var (
+		load    []Key
+		leaders = make(map[Key]*call)
+		waits   = make(map[Key]*call)
+	)
+	for _, key := range missing {
+		cl, leader := c.flight.join(key)
+		if !leader {
+			waits[key] = cl
+			continue
+		}
+		leaders[key] = cl
+		if e, ok := c.live(key); ok {
+			if !e.isNegative() {
+				values[key] = e.value
+			}
+			continue
+		}
+		load = append(load, key)
+	}
+	var (
+		loaded map[Key]interface{}
+		err    error
+	)
+	// leaders are finished before waiting for others, otherwise two callers leading keys of each other
+	// would wait forever. The deferred call finish them if loader panics.
+	finish := func() {
+		for key, cl := range leaders {
+			if v, ok := values[key]; ok {
+				c.flight.finish(key, cl, v, nil)
+			} else if err != nil {
+				c.flight.finish(key, cl, nil, err)
+			} else {
+				c.flight.finish(key, cl, nil, ErrNoSuchKey)
+			}
+		}
+		leaders = nil
+	}
+	defer finish()
+	if len(load) > 0 {
+		loaded, err = c.loadMulti(load, loader)
+		for _, key := range load {
+			if v, ok := loaded[key]; ok {
+				c.SetWithExpire(key, v, ttl)
+				values[key] = v
+			}
+		}
+	}
+	finish()
+	for key, cl := range waits {
+		<-cl.done
+		if cl.err == nil && !cl.panicked && !cl.abandoned {
+			values[key] = cl.value
+		}
+	}
+	if c.config.CopyOnRead {
+		for key, v := range values {
+			v, cerr := c.copyOut(v)
+			if cerr != nil {
+				delete(values, key)
+				if err == nil {
+					err = cerr
+				}
+				continue
+			}
+			values[key] = v
+		}
+	}
+	return values, err
+}
+
+// loadMulti call loader with keys while holding a slot of MaxConcurrentLoads, the slot is released even if
+// loader panics.
+func (c *LocalCache) loadMulti(keys []Key, loader func(missing []Key) (map[Key]interface{}, error)) (map[Key]interface{}, error) {
+	if err := c.acquireLoad(context.Background()); err != nil {
+		return nil, err
+	}
+	defer c.releaseLoad()
+	return loader(keys)
+}
+
+// acquireLoad wait for a slot of MaxConcurrentLoads until ctx is done, or return ErrTooManyLoads at once
+// if LoadFailFast configured. A nil error means the caller must call releaseLoad after loading.
+func (c *LocalCache) acquireLoad(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.loads == nil {
+		return nil
+	}
+	if c.config.LoadFailFast {
+		select {
+		case c.loads <- struct{}{}:
+			return nil
+		default:
+			return ErrTooManyLoads
+		}
+	}
+	select {
+	case c.loads <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// releaseLoad release the slot acquired by acquireLoad.
+func (c *LocalCache) releaseLoad() {
+	if c.loads != nil {
+		<-c.loads
+	}
+}
+
+// GetOrLoad will do same as GetOrCompute, but an error returned by loader will be cached for errTTL,
+// so calls within errTTL return the cached error without calling loader again.
+// A errTTL <= 0 means errors will not be cached.
+func (c *LocalCache) GetOrLoad(key Key, ttl, errTTL time.Duration, loader func() (interface{}, error)) (interface{}, error) {
+	return c.GetOrCompute(key, ttl, func() (interface{}, error) {
+		v, err := loader()
+		if err != nil && errTTL > 0 {
+			c.SetWithExpire(key, negative{err: err}, errTTL)
+		}
+		return v, err
+	})
+}
+
+// Do call fn once for concurrent callers of the same key and return its result to all of them,
+// the result is not cached. It is independent from the loads of GetOrCompute even for the same key.
+// A panic in fn will be propagated to all callers waiting for it.
+func (c *LocalCache) Do(key Key, fn func() (interface{}, error)) (interface{}, error) {
+	return c.calls.do(key, fn)
+}
+
+// refreshAhead reload key in background if entry will expire within RefreshAhead,
+// only one reload of the same key will be in flight, shared with GetOrCompute.
+func (c *LocalCache) refreshAhead(key Key, entry Entry) {
+	if c.config.RefreshAhead <= 0 || c.config.Loader == nil || entry.expire == 0 {
+		return
+	}
+	if time.Duration(entry.expire-c.clock.Now().UnixNano()) > c.config.RefreshAhead {
+		return
+	}
+	cl, leader := c.flight.join(key)
+	if !leader {
+		return
+	}
+	go func() {
+		var v interface{}
+		err := c.acquireLoad(context.Background())
+		if err == nil {
+			panicked := true
+			c.protect(func() {
+				v, err = c.config.Loader(key)
+				panicked = false
+			})
+			c.releaseLoad()
+			if panicked {
+				// the panic has been reported to OnCallbackError, waiters retry the load themselves.
+				cl.abandoned, err = true, ErrNoSuchKey
+			}
+		}
+		if err == nil {
+			c.Set(key, v)
+		}
+		c.flight.finish(key, cl, v, err)
+	}()
+}
+
+// WarmMulti load keys not exist or expired by loader with at most concurrency loads in parallel,
+// and store the loaded values with the ttl returned by loader. Keys already live are skipped.
+// Errors returned by loader are collected by key, nil if all keys are loaded successfully.
+// A panic in loader stops no other loads, it is propagated to the caller after all loads are done.
+func (c *LocalCache) WarmMulti(keys []Key, concurrency int, loader func(Key) (interface{}, time.Duration, error)) map[Key]error {
+	if c.isClosed() {
+		return closedErrs(keys)
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var (
+		mu       sync.Mutex
+		errs     map[Key]error
+		panicked bool
+		panicVal interface{}
+		wg       sync.WaitGroup
+	)
+	queue := make(chan Key)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range queue {
+				failed, r, err := c.warm(key, loader)
+				if failed {
+					mu.Lock()
+					if !panicked {
+						panicked, panicVal = true, r
+					}
+					mu.Unlock()
+					continue
+				}
+				if err != nil {
+					mu.Lock()
+					if errs == nil {
+						errs = make(map[Key]error)
+					}
+					errs[key] = err
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, key := range keys {
+		if !c.Has(key) {
+			queue <- key
+		}
+	}
+	close(queue)
+	wg.Wait()
+	if panicked {
+		panic(panicVal)
+	}
+	return errs
+}
+
+// warm load key by loader while holding a slot of MaxConcurrentLoads and store the value, the slot is
+// released even if loader panics, the panic is recovered and returned as r with panicked set.
+func (c *LocalCache) warm(key Key, loader func(Key) (interface{}, time.Duration, error)) (panicked bool, r interface{}, err error) {
+	if err := c.acquireLoad(context.Background()); err != nil {
+		return false, nil, err
+	}
+	defer c.releaseLoad()
+	panicked = true
+	defer func() {
+		if panicked {
+			r = recover()
+		}
+	}()
+	v, ttl, err := loader(key)
+	if err == nil {
+		c.SetWithExpire(key, v, ttl)
+	}
+	return false, nil, err
+}
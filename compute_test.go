@@ -0,0 +1,555 @@
+package localcache_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leaxoy/localcache"
+)
+
+func TestLocalCache_GetOrCompute(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	var calls int32
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return "value", nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := localCache.GetOrCompute("xxx", time.Minute, loader)
+			if err != nil {
+				t.Error(err)
+			}
+			if v != "value" {
+				t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "value", v)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("err: not equal, expect: %+v calls, but got: %+v\n", 1, calls)
+	}
+	errLoad := errors.New("load failed")
+	_, err := localCache.GetOrCompute("yyy", time.Minute, func() (interface{}, error) { return nil, errLoad })
+	if err != errLoad {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", errLoad, err)
+	}
+	if localCache.Has("yyy") {
+		t.Errorf("err: expect error not cached\n")
+	}
+}
+
+func TestLocalCache_GetOrComputeContext(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-release
+		return "value", nil
+	}
+	winner := make(chan error, 1)
+	go func() {
+		_, err := localCache.GetOrComputeContext(context.Background(), "xxx", time.Minute, loader)
+		winner <- err
+	}()
+	<-started
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := localCache.GetOrComputeContext(ctx, "xxx", time.Minute, loader)
+		canceled <- err
+	}()
+	cancel()
+	select {
+	case err := <-canceled:
+		if err != context.Canceled {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", context.Canceled, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("err: canceled caller blocked")
+	}
+	close(release)
+	if err := <-winner; err != nil {
+		t.Error(err)
+	}
+	v, err := localCache.Get("xxx")
+	if err != nil {
+		t.Error(err)
+	}
+	if v != "value" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "value", v)
+	}
+}
+
+func TestLocalCache_GetOrComputePanic(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	compute := func(loader func() (interface{}, error)) (r interface{}) {
+		defer func() { r = recover() }()
+		localCache.GetOrCompute("xxx", time.Minute, loader)
+		return nil
+	}
+	leader := make(chan interface{}, 1)
+	go func() {
+		leader <- compute(func() (interface{}, error) {
+			close(started)
+			<-release
+			panic("bad loader")
+		})
+	}()
+	<-started
+	waiter := make(chan interface{}, 1)
+	go func() {
+		waiter <- compute(func() (interface{}, error) { return "value", nil })
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if r := <-leader; r != "bad loader" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "bad loader", r)
+	}
+	if r := <-waiter; r != "bad loader" {
+		t.Errorf("err: expect panic propagated to waiter, but got: %+v\n", r)
+	}
+	if localCache.Has("xxx") {
+		t.Errorf("err: expect nothing stored by panicked loader\n")
+	}
+}
+
+func TestLocalCache_GetOrComputeLeaderCanceled(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	started := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := localCache.GetOrComputeContext(ctx, "xxx", time.Minute, func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		leader <- err
+	}()
+	<-started
+	waiter := make(chan interface{}, 1)
+	go func() {
+		v, _ := localCache.GetOrComputeContext(context.Background(), "xxx", time.Minute, func(ctx context.Context) (interface{}, error) {
+			return "value", nil
+		})
+		waiter <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-leader; err != context.Canceled {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", context.Canceled, err)
+	}
+	if v := <-waiter; v != "value" {
+		t.Errorf("err: expect waiter retry the load, but got: %+v\n", v)
+	}
+}
+
+func TestLocalCache_Do(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	var calls int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := localCache.Do("xxx", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return "value", nil
+			})
+			if err != nil || v != "value" {
+				t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", "value", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("err: not equal, expect: %+v calls, but got: %+v\n", 1, calls)
+	}
+	if localCache.Has("xxx") {
+		t.Errorf("err: expect result of Do not cached\n")
+	}
+
+	var panics int32
+	release = make(chan struct{})
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r == "boom" {
+					atomic.AddInt32(&panics, 1)
+				}
+			}()
+			localCache.Do("yyy", func() (interface{}, error) {
+				<-release
+				panic("boom")
+			})
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if panics != 10 {
+		t.Errorf("err: not equal, expect: %+v panics, but got: %+v\n", 10, panics)
+	}
+	if v, err := localCache.Do("yyy", func() (interface{}, error) { return 1, nil }); err != nil || v != 1 {
+		t.Errorf("err: expect in-flight call cleaned up, but got: %+v, %+v\n", v, err)
+	}
+}
+
+func TestLocalCache_GetOrLoad(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	var calls int32
+	errLoad := errors.New("backend unavailable")
+	fail := true
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		if fail {
+			return nil, errLoad
+		}
+		return "value", nil
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := localCache.GetOrLoad("xxx", time.Minute, 50*time.Millisecond, loader); err != errLoad {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", errLoad, err)
+		}
+	}
+	if _, err := localCache.Get("xxx"); err != errLoad {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", errLoad, err)
+	}
+	if calls != 1 {
+		t.Errorf("err: not equal, expect: %+v calls, but got: %+v\n", 1, calls)
+	}
+	fail = false
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if v, err := localCache.GetOrLoad("xxx", time.Minute, 50*time.Millisecond, loader); err != nil || v != "value" {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", "value", v, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("err: not equal, expect: %+v calls, but got: %+v\n", 2, calls)
+	}
+}
+
+func TestLocalCache_RefreshAhead(t *testing.T) {
+	var loads int32
+	gate := make(chan struct{})
+	loader := func(key localcache.Key) (interface{}, error) {
+		<-gate
+		return int(atomic.AddInt32(&loads, 1)), nil
+	}
+	var localCache = localcache.New(
+		localcache.WithExpiration(100*time.Millisecond),
+		localcache.WithRefreshAhead(50*time.Millisecond, loader),
+	)
+	defer localCache.Close()
+	localCache.Set("xxx", 0)
+	if v, _ := localCache.Get("xxx"); v != 0 || atomic.LoadInt32(&loads) != 0 {
+		t.Errorf("err: expect no refresh out of window, but got: %+v, %+v loads\n", v, loads)
+	}
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		if v, err := localCache.Get("xxx"); err != nil || v != 0 {
+			t.Errorf("err: expect stale value returned without blocking, but got: %+v, %+v\n", v, err)
+		}
+	}
+	close(gate)
+	time.Sleep(20 * time.Millisecond)
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("err: not equal, expect: %+v loads, but got: %+v\n", 1, n)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if v, err := localCache.Get("xxx"); err != nil || v != 1 {
+		t.Errorf("err: expect refreshed value alive after original expiration, but got: %+v, %+v\n", v, err)
+	}
+}
+
+func TestLocalCache_RefreshAheadPanic(t *testing.T) {
+	var localCache = localcache.New(
+		localcache.WithExpiration(time.Minute),
+		localcache.WithRefreshAhead(2*time.Minute, func(key localcache.Key) (interface{}, error) {
+			panic("bad loader")
+		}),
+	)
+	defer localCache.Close()
+	recovered := make(chan interface{}, 2)
+	localCache.OnCallbackError(func(r interface{}) { recovered <- r })
+	localCache.Set("xxx", 0)
+	deadline := time.Now().Add(time.Second)
+	// every Get start a refresh once the previous one has finished.
+	for n := 0; n < 2; {
+		if v, err := localCache.Get("xxx"); err != nil || v != 0 {
+			t.Fatalf("err: expect value kept, but got: %+v, %+v\n", v, err)
+		}
+		select {
+		case r := <-recovered:
+			if r != "bad loader" {
+				t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "bad loader", r)
+			}
+			n++
+		case <-time.After(time.Millisecond):
+			if time.Now().After(deadline) {
+				t.Fatalf("err: expect panics of refresh recovered, but got: %+v\n", n)
+			}
+		}
+	}
+}
+
+func TestLocalCache_WarmMulti(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	localCache.Set(0, "cached")
+	var calls, running, peak int32
+	errLoad := errors.New("load failed")
+	loader := func(key localcache.Key) (interface{}, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		if key == 7 {
+			return nil, 0, errLoad
+		}
+		return key, time.Minute, nil
+	}
+	keys := make([]localcache.Key, 20)
+	for i := range keys {
+		keys[i] = i
+	}
+	errs := localCache.WarmMulti(keys, 4, loader)
+	if len(errs) != 1 || errs[7] != errLoad {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", map[localcache.Key]error{7: errLoad}, errs)
+	}
+	if calls != 19 {
+		t.Errorf("err: not equal, expect: %+v calls, but got: %+v\n", 19, calls)
+	}
+	if peak > 4 {
+		t.Errorf("err: expect at most %+v loads in parallel, but got: %+v\n", 4, peak)
+	}
+	if v, _ := localCache.Get(0); v != "cached" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "cached", v)
+	}
+	if v, _ := localCache.Get(19); v != 19 || localCache.Has(7) {
+		t.Errorf("err: expect loaded keys stored except failed ones, but got: %+v\n", v)
+	}
+}
+
+func TestLocalCache_WarmMultiPanic(t *testing.T) {
+	var localCache = localcache.New(localcache.WithMaxConcurrentLoads(1, false))
+	defer localCache.Close()
+	loader := func(key localcache.Key) (interface{}, time.Duration, error) {
+		if key == 3 {
+			panic("bad loader")
+		}
+		return key, time.Minute, nil
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != "bad loader" {
+				t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "bad loader", r)
+			}
+		}()
+		localCache.WarmMulti([]localcache.Key{1, 2, 3, 4, 5}, 2, loader)
+	}()
+	if v, _ := localCache.Get(5); v != 5 || localCache.Has(3) {
+		t.Errorf("err: expect other keys loaded, but got: %+v\n", v)
+	}
+	if errs := localCache.WarmMulti([]localcache.Key{6}, 1, loader); errs != nil {
+		t.Errorf("err: expect the load slot released, but got: %+v\n", errs)
+	}
+}
+
+func TestLocalCache_GetOrComputeMulti(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	localCache.Set(1, "one")
+	localCache.Set(3, "three")
+	var received []localcache.Key
+	loader := func(missing []localcache.Key) (map[localcache.Key]interface{}, error) {
+		received = append(received, missing...)
+		values := make(map[localcache.Key]interface{})
+		for _, key := range missing {
+			if key != 5 {
+				values[key] = key.(int) * 10
+			}
+		}
+		return values, nil
+	}
+	values, err := localCache.GetOrComputeMulti([]localcache.Key{1, 2, 3, 4, 5}, time.Minute, loader)
+	if err != nil {
+		t.Error(err)
+	}
+	sort.Slice(received, func(i, j int) bool { return received[i].(int) < received[j].(int) })
+	if !reflect.DeepEqual(received, []localcache.Key{2, 4, 5}) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", []localcache.Key{2, 4, 5}, received)
+	}
+	expect := map[localcache.Key]interface{}{1: "one", 2: 20, 3: "three", 4: 40}
+	if !reflect.DeepEqual(values, expect) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect, values)
+	}
+	if v, _ := localCache.Get(4); v != 40 {
+		t.Errorf("err: expect loaded value stored, but got: %+v\n", v)
+	}
+	errLoad := errors.New("backend unavailable")
+	if _, err := localCache.GetOrComputeMulti([]localcache.Key{6}, time.Minute, func([]localcache.Key) (map[localcache.Key]interface{}, error) {
+		return nil, errLoad
+	}); err != errLoad || localCache.Has(6) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", errLoad, err)
+	}
+}
+
+func TestLocalCache_GetOrComputeMultiDedup(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	var loads int32
+	gate := make(chan struct{})
+	loader := func(missing []localcache.Key) (map[localcache.Key]interface{}, error) {
+		atomic.AddInt32(&loads, int32(len(missing)))
+		<-gate
+		values := make(map[localcache.Key]interface{})
+		for _, key := range missing {
+			values[key] = key
+		}
+		return values, nil
+	}
+	var wg sync.WaitGroup
+	for _, keys := range [][]localcache.Key{{1, 2}, {2, 1}, {1, 2, 3}} {
+		keys := keys
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if values, err := localCache.GetOrComputeMulti(keys, time.Minute, loader); err != nil || len(values) != len(keys) {
+				t.Errorf("err: expect all keys loaded, but got: %+v, %+v\n", values, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(gate)
+	wg.Wait()
+	if n := atomic.LoadInt32(&loads); n != 3 {
+		t.Errorf("err: not equal, expect: %+v loaded keys, but got: %+v\n", 3, n)
+	}
+}
+
+func TestLocalCache_GetOrComputeMultiSharedPanic(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	started, gate, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != "bad loader" {
+				t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "bad loader", r)
+			}
+		}()
+		localCache.GetOrCompute(1, time.Minute, func() (interface{}, error) {
+			close(started)
+			<-gate
+			panic("bad loader")
+		})
+	}()
+	<-started
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(gate)
+	}()
+	values, err := localCache.GetOrComputeMulti([]localcache.Key{1, 2}, time.Minute, func(missing []localcache.Key) (map[localcache.Key]interface{}, error) {
+		values := make(map[localcache.Key]interface{})
+		for _, key := range missing {
+			values[key] = key
+		}
+		return values, nil
+	})
+	<-done
+	if err != nil || !reflect.DeepEqual(values, map[localcache.Key]interface{}{2: 2}) {
+		t.Errorf("err: expect the panicked key absent, but got: %+v, %+v\n", values, err)
+	}
+}
+
+func TestLocalCache_MaxConcurrentLoads(t *testing.T) {
+	var localCache = localcache.New(localcache.WithMaxConcurrentLoads(3, false))
+	defer localCache.Close()
+	var running, peak int32
+	loader := func() (interface{}, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return 1, nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := localCache.GetOrCompute(i, time.Minute, loader); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if p := atomic.LoadInt32(&peak); p > 3 || p == 0 {
+		t.Errorf("err: expect at most %+v concurrent loads, but got: %+v\n", 3, p)
+	}
+
+	var failFast = localcache.New(localcache.WithMaxConcurrentLoads(1, true))
+	defer failFast.Close()
+	gate, started := make(chan struct{}), make(chan struct{})
+	go failFast.GetOrCompute("slow", time.Minute, func() (interface{}, error) {
+		close(started)
+		<-gate
+		return 1, nil
+	})
+	<-started
+	if _, err := failFast.GetOrCompute("other", time.Minute, loader); err != localcache.ErrTooManyLoads {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrTooManyLoads, err)
+	}
+	close(gate)
+	var single = localcache.New(localcache.WithMaxConcurrentLoads(1, true))
+	defer single.Close()
+	func() {
+		defer func() { recover() }()
+		single.GetOrComputeMulti([]localcache.Key{"x"}, time.Minute, func(missing []localcache.Key) (map[localcache.Key]interface{}, error) {
+			panic("bad loader")
+		})
+	}()
+	values, err := single.GetOrComputeMulti([]localcache.Key{"x"}, time.Minute, func(missing []localcache.Key) (map[localcache.Key]interface{}, error) {
+		return map[localcache.Key]interface{}{"x": 1}, nil
+	})
+	if err != nil || values["x"] != 1 {
+		t.Errorf("err: expect load slot released after panic, but got: %+v, %+v\n", values, err)
+	}
+}
@@ -0,0 +1,91 @@
+package localcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+)
+
+// GetCopy will do same as Get but return a deep copy of slices, arrays and maps, including the ones nested
+// in them, so callers can modify the result without affecting the cached value. Values of other kinds,
+// such as pointers, structs and channels, are returned as is and still share state with the cache.
+func (c *LocalCache) GetCopy(key Key) (interface{}, error) {
+	v, err := c.Get(key)
+	if err != nil || v == nil {
+		return v, err
+	}
+	return deepCopy(reflect.ValueOf(v)).Interface(), nil
+}
+
+// copyOut return a deep copy of v made by gobCopy if CopyOnRead configured, otherwise v itself.
+func (c *LocalCache) copyOut(v interface{}) (interface{}, error) {
+	if !c.config.CopyOnRead || v == nil {
+		return v, nil
+	}
+	return gobCopy(v)
+}
+
+// gobCopy return a deep copy of v made by a round trip of encoding/gob.
+func gobCopy(v interface{}) (interface{}, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	var c interface{}
+	if err := gob.NewDecoder(&buf).Decode(&c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// deepCopy copy slices, arrays, maps and interfaces recursively, other values are returned as is.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		if flat(v.Type().Elem()) {
+			reflect.Copy(c, v)
+			return c
+		}
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	}
+	return v
+}
+
+// flat report whether values of t can be copied without deepCopy.
+func flat(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Interface:
+		return false
+	}
+	return true
+}
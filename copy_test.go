@@ -0,0 +1,125 @@
+package localcache_test
+
+import (
+	"context"
+	"encoding/gob"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/leaxoy/localcache"
+)
+
+func TestLocalCache_GetCopy(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	localCache.Set("slice", []int{1, 2, 3})
+	localCache.Set("bytes", []byte("abc"))
+	localCache.Set("map", map[string]interface{}{"list": []string{"a"}})
+	v, _ := localCache.GetCopy("slice")
+	v.([]int)[0] = 100
+	b, _ := localCache.GetCopy("bytes")
+	b.([]byte)[0] = 'x'
+	m, _ := localCache.GetCopy("map")
+	m.(map[string]interface{})["list"].([]string)[0] = "b"
+	m.(map[string]interface{})["new"] = 1
+	if v, _ := localCache.Get("slice"); !reflect.DeepEqual(v, []int{1, 2, 3}) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", []int{1, 2, 3}, v)
+	}
+	if v, _ := localCache.Get("bytes"); string(v.([]byte)) != "abc" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "abc", string(v.([]byte)))
+	}
+	expect := map[string]interface{}{"list": []string{"a"}}
+	if v, _ := localCache.Get("map"); !reflect.DeepEqual(v, expect) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect, v)
+	}
+	if _, err := localCache.GetCopy("missing"); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+}
+
+type copyProfile struct {
+	Name   string
+	Tags   []string
+	Scores map[string][]int
+	Friend *copyProfile
+}
+
+func init() {
+	gob.Register(copyProfile{})
+}
+
+func TestLocalCache_CopyOnRead(t *testing.T) {
+	var localCache = localcache.New(localcache.WithCopyOnRead())
+	defer localCache.Close()
+	stored := copyProfile{
+		Name:   "a",
+		Tags:   []string{"x", "y"},
+		Scores: map[string][]int{"math": {1, 2}},
+		Friend: &copyProfile{Name: "b", Tags: []string{"z"}},
+	}
+	localCache.Set("profile", stored)
+	v, err := localCache.Get("profile")
+	if err != nil || !reflect.DeepEqual(v, stored) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", stored, v, err)
+	}
+	got := v.(copyProfile)
+	got.Tags[0] = "changed"
+	got.Scores["math"][0] = 100
+	got.Friend.Name = "changed"
+	got.Friend.Tags[0] = "changed"
+	again, _ := localCache.Get("profile")
+	p := again.(copyProfile)
+	if p.Tags[0] != "x" || p.Scores["math"][0] != 1 || p.Friend.Name != "b" || p.Friend.Tags[0] != "z" {
+		t.Errorf("err: expect cached value untouched, but got: %+v, %+v\n", p, p.Friend)
+	}
+	if stored.Tags[0] != "x" || stored.Friend.Name != "b" {
+		t.Errorf("err: expect stored value untouched, but got: %+v\n", stored)
+	}
+	localCache.Set("unregistered", struct{ A int }{1})
+	if _, err := localCache.Get("unregistered"); err == nil {
+		t.Errorf("err: expect error of unregistered type\n")
+	}
+}
+
+func TestLocalCache_CopyOnReadPaths(t *testing.T) {
+	var localCache = localcache.New(localcache.WithCopyOnRead())
+	defer localCache.Close()
+	localCache.Set("xxx", []int{1, 2})
+	reads := map[string]func() interface{}{
+		"Peek": func() interface{} { v, _ := localCache.Peek("xxx"); return v },
+		"GetMulti": func() interface{} {
+			found, _ := localCache.GetMulti([]localcache.Key{"xxx"})
+			return found["xxx"]
+		},
+		"GetKeysEntry": func() interface{} { return localCache.GetKeysEntry([]localcache.Key{"xxx"})["xxx"].Value },
+		"GetOrComputeMulti": func() interface{} {
+			values, _ := localCache.GetOrComputeMulti([]localcache.Key{"xxx"}, time.Minute, nil)
+			return values["xxx"]
+		},
+		"WaitFor": func() interface{} { v, _ := localCache.WaitFor(context.Background(), "xxx"); return v },
+	}
+	for name, read := range reads {
+		v, ok := read().([]int)
+		if !ok {
+			t.Errorf("err: %s expect []int, but got: %+v\n", name, v)
+			continue
+		}
+		v[0] = 100
+		if cached, _ := localCache.Peek("xxx"); cached.([]int)[0] != 1 {
+			t.Errorf("err: %s expect cached value untouched, but got: %+v\n", name, cached)
+		}
+	}
+	v, _ := localCache.GetOrCompute("computed", time.Minute, func() (interface{}, error) { return []int{1, 2}, nil })
+	v.([]int)[0] = 100
+	if cached, _ := localCache.Peek("computed"); cached.([]int)[0] != 1 {
+		t.Errorf("err: expect computed value untouched, but got: %+v\n", cached)
+	}
+	localCache.SetWithExpire("stale", []int{1, 2}, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	v, stale, _ := localCache.GetStale("stale")
+	v.([]int)[0] = 100
+	if again, _, _ := localCache.GetStale("stale"); !stale || again.([]int)[0] != 1 {
+		t.Errorf("err: expect stale value untouched, but got: %+v\n", again)
+	}
+}
@@ -0,0 +1,268 @@
+package localcache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+const defaultEventBuffer = 1024
+
+// Op is the kind of mutation described by an Event.
+type Op int
+
+const (
+	// OpSet indicate the value of key has been stored or updated.
+	OpSet Op = iota
+	// OpDelete indicate the key has been removed manually, or cached as not found.
+	OpDelete
+	// OpExpire indicate the key has expired and been removed.
+	OpExpire
+	// OpEvict indicate the key has been evicted to make room for others.
+	OpEvict
+)
+
+// String return the name of op.
+func (op Op) String() string {
+	switch op {
+	case OpSet:
+		return "set"
+	case OpDelete:
+		return "delete"
+	case OpExpire:
+		return "expire"
+	case OpEvict:
+		return "evict"
+	}
+	return "unknown"
+}
+
+// Event describe a mutation of cache, Value is the new value for OpSet and the removed value for others.
+type Event struct {
+	Key   Key
+	Op    Op
+	Value interface{}
+}
+
+// subscription is the channel of a subscriber, done is closed by Unsubscribe to release blocked senders.
+type subscription struct {
+	ch   chan Event
+	done chan struct{}
+	once sync.Once
+}
+
+// release close done of sub once to release senders blocked on it, it takes no lock.
+func (sub *subscription) release() {
+	sub.once.Do(func() { close(sub.done) })
+}
+
+// eventHub fan out events to subscriptions and wake waiters of WaitFor when their keys are set.
+// mu is held for reading while sending to subscriptions, so waiters are guarded by their own wmu and
+// subscriptions can be released through index without mu, no write lock waits for a blocking send.
+// subscribed and waiting are the numbers of subscriptions and waiters read without lock.
+type eventHub struct {
+	mu         sync.RWMutex
+	subs       []*subscription
+	index      sync.Map // the subscription of each channel returned by Subscribe, keyed by <-chan Event
+	subscribed int32
+	wmu        sync.RWMutex
+	waiters    map[Key][]chan struct{}
+	waiting    int32
+	closed     bool // written while holding both mu and wmu
+}
+
+// listened report whether there is any subscription or waiter, so events need to be recorded.
+func (h *eventHub) listened() bool {
+	return atomic.LoadInt32(&h.subscribed) > 0 || atomic.LoadInt32(&h.waiting) > 0
+}
+
+// wait register a waiter of key, the returned channel is closed when key is set or hub closed.
+func (h *eventHub) wait(key Key) chan struct{} {
+	ch := make(chan struct{})
+	h.wmu.Lock()
+	defer h.wmu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch
+	}
+	if h.waiters == nil {
+		h.waiters = make(map[Key][]chan struct{})
+	}
+	h.waiters[key] = append(h.waiters[key], ch)
+	atomic.AddInt32(&h.waiting, 1)
+	return ch
+}
+
+// cancel unregister a waiter of key which has not been woken.
+func (h *eventHub) cancel(key Key, ch chan struct{}) {
+	h.wmu.Lock()
+	defer h.wmu.Unlock()
+	chs := h.waiters[key]
+	for i := range chs {
+		if chs[i] == ch {
+			chs = append(chs[:i], chs[i+1:]...)
+			atomic.AddInt32(&h.waiting, -1)
+			break
+		}
+	}
+	if len(chs) == 0 {
+		delete(h.waiters, key)
+	} else {
+		h.waiters[key] = chs
+	}
+}
+
+// wake close channels of all waiters of key, the write lock is taken only if key has waiters.
+func (h *eventHub) wake(key Key) {
+	h.wmu.RLock()
+	n := len(h.waiters[key])
+	h.wmu.RUnlock()
+	if n == 0 {
+		return
+	}
+	h.wmu.Lock()
+	defer h.wmu.Unlock()
+	for _, ch := range h.waiters[key] {
+		close(ch)
+		atomic.AddInt32(&h.waiting, -1)
+	}
+	delete(h.waiters, key)
+}
+
+// Subscribe return a channel receiving an Event for every mutation of cache. Events of one call arrive in
+// the order they happen, but events are published after the lock of shard released, so events of concurrent
+// calls may arrive in any order, even for the same key. The channel is buffered by EventBuffer, events are dropped when it is full unless
+// EventBlock configured, which makes mutations wait for slow subscribers. The channel will be closed
+// by Unsubscribe or Close, a closed channel returned if cache has been closed.
+func (c *LocalCache) Subscribe() <-chan Event {
+	size := c.config.EventBuffer
+	if size <= 0 {
+		size = defaultEventBuffer
+	}
+	sub := &subscription{ch: make(chan Event, size), done: make(chan struct{})}
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	if c.hub.closed {
+		close(sub.ch)
+		return sub.ch
+	}
+	c.hub.subs = append(c.hub.subs, sub)
+	c.hub.index.Store((<-chan Event)(sub.ch), sub)
+	atomic.AddInt32(&c.hub.subscribed, 1)
+	return sub.ch
+}
+
+// Unsubscribe stop delivering events to ch returned by Subscribe and close it.
+func (c *LocalCache) Unsubscribe(ch <-chan Event) {
+	if sub, ok := c.hub.index.Load(ch); ok {
+		sub.(*subscription).release()
+	}
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	for i, sub := range c.hub.subs {
+		if sub.ch == ch {
+			c.hub.subs = append(c.hub.subs[:i], c.hub.subs[i+1:]...)
+			c.hub.index.Delete((<-chan Event)(sub.ch))
+			atomic.AddInt32(&c.hub.subscribed, -1)
+			close(sub.ch)
+			return
+		}
+	}
+}
+
+// closeEvents close all subscriptions, the following Subscribe will return closed channels.
+func (c *LocalCache) closeEvents() {
+	c.hub.index.Range(func(_, sub interface{}) bool {
+		sub.(*subscription).release()
+		return true
+	})
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	c.hub.wmu.Lock()
+	defer c.hub.wmu.Unlock()
+	for _, sub := range c.hub.subs {
+		c.hub.index.Delete((<-chan Event)(sub.ch))
+		close(sub.ch)
+	}
+	for _, chs := range c.hub.waiters {
+		for _, ch := range chs {
+			close(ch)
+		}
+	}
+	c.hub.subs = nil
+	c.hub.waiters = nil
+	c.hub.closed = true
+	atomic.StoreInt32(&c.hub.subscribed, 0)
+	atomic.StoreInt32(&c.hub.waiting, 0)
+}
+
+// emit record an event which will be published by unlock after the lock of s released.
+// The caller must hold the write lock of s.
+func (c *LocalCache) emit(s *shard, key Key, op Op, entry Entry) {
+	if op == OpSet && c.config.WriteBack != nil && !entry.isNegative() {
+		c.dirty.mark(key)
+	}
+	if !c.hub.listened() {
+		return
+	}
+	if op == OpSet && entry.isNegative() {
+		op = OpDelete
+	}
+	value := entry.value
+	if entry.isNegative() {
+		value = nil
+	}
+	s.events = append(s.events, Event{Key: key, Op: op, Value: value})
+}
+
+// publish deliver events to all subscriptions and wake waiters of set keys.
+func (c *LocalCache) publish(events []Event) {
+	if atomic.LoadInt32(&c.hub.waiting) > 0 {
+		for _, ev := range events {
+			if ev.Op == OpSet {
+				c.hub.wake(ev.Key)
+			}
+		}
+	}
+	if atomic.LoadInt32(&c.hub.subscribed) == 0 {
+		return
+	}
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+	for _, ev := range events {
+		for _, sub := range c.hub.subs {
+			if c.config.EventBlock {
+				select {
+				case sub.ch <- ev:
+				case <-sub.done:
+				}
+				continue
+			}
+			select {
+			case sub.ch <- ev:
+			default:
+			}
+		}
+	}
+}
+
+// WaitFor block until key is set with a live value and return it, or return ctx.Err() when ctx is done.
+// A key already in cache is returned at once. It returns ErrCacheClosed if cache has been closed.
+func (c *LocalCache) WaitFor(ctx context.Context, key Key) (interface{}, error) {
+	for {
+		if c.isClosed() {
+			return nil, ErrCacheClosed
+		}
+		ch := c.hub.wait(key)
+		if e, ok := c.live(key); ok && !e.isNegative() {
+			c.hub.cancel(key, ch)
+			return c.copyOut(e.value)
+		}
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			c.hub.cancel(key, ch)
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package localcache_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leaxoy/localcache"
+)
+
+func TestLocalCache_Subscribe(t *testing.T) {
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithClock(clock), localcache.WithMaxEntries(2))
+	events := localCache.Subscribe()
+	other := localCache.Subscribe()
+	localCache.Set("1", 1)
+	localCache.Set("1", 10)
+	localCache.Delete("1")
+	localCache.SetWithExpire("2", 2, time.Second)
+	clock.Advance(2 * time.Second)
+	localCache.Get("2")
+	localCache.Set("3", 3)
+	localCache.Set("4", 4)
+	localCache.Set("5", 5)
+	localCache.Unsubscribe(other)
+	localCache.Set("6", 6)
+	localCache.Close()
+	expect := []localcache.Event{
+		{Key: "1", Op: localcache.OpSet, Value: 1},
+		{Key: "1", Op: localcache.OpSet, Value: 10},
+		{Key: "1", Op: localcache.OpDelete, Value: 10},
+		{Key: "2", Op: localcache.OpSet, Value: 2},
+		{Key: "2", Op: localcache.OpExpire, Value: 2},
+		{Key: "3", Op: localcache.OpSet, Value: 3},
+		{Key: "4", Op: localcache.OpSet, Value: 4},
+		{Key: "3", Op: localcache.OpEvict, Value: 3},
+		{Key: "5", Op: localcache.OpSet, Value: 5},
+	}
+	var got, gotOther []localcache.Event
+	for ev := range events {
+		got = append(got, ev)
+	}
+	for ev := range other {
+		gotOther = append(gotOther, ev)
+	}
+	if !reflect.DeepEqual(got[:len(expect)], expect) || len(got) != len(expect)+2 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect, got)
+	}
+	if !reflect.DeepEqual(gotOther, expect) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect, gotOther)
+	}
+	if _, ok := <-localCache.Subscribe(); ok {
+		t.Errorf("err: expect closed channel after Close\n")
+	}
+}
+
+func TestLocalCache_WaitFor(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if v, err := localCache.WaitFor(ctx, "xxx"); err != nil || v != 1 {
+				t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", 1, v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	localCache.Set("other", 2)
+	go localCache.Set("xxx", 1)
+	wg.Wait()
+	if v, err := localCache.WaitFor(context.Background(), "other"); err != nil || v != 2 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", 2, v, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := localCache.WaitFor(ctx, "missing"); err != context.DeadlineExceeded {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", context.DeadlineExceeded, err)
+	}
+}
+
+func TestLocalCache_WaitForEventBlock(t *testing.T) {
+	var localCache = localcache.NewLocalCache(&localcache.CacheConfig{EventBuffer: 1, EventBlock: true})
+	defer localCache.Close()
+	events := localCache.Subscribe()
+	localCache.Set("a", 1)
+	blocked := make(chan struct{})
+	go func() {
+		defer close(blocked)
+		localCache.Set("b", 2)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if v, err := localCache.WaitFor(ctx, "xxx"); err != nil || v != 1 {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", 1, v, err)
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	go localCache.Set("xxx", 1)
+	<-done
+	localCache.Unsubscribe(events)
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		t.Errorf("err: expect blocked Set released by Unsubscribe\n")
+	}
+}
@@ -0,0 +1,66 @@
+package localcache
+
+// eviction is a pending call of the callbacks with the ones set when it happened.
+type eviction struct {
+	fn       func(Key, Entry)
+	handlers []func(key Key, value interface{}, reason EvictReason)
+	key      Key
+	entry    Entry
+	reason   EvictReason
+}
+
+// dispatch call the callbacks by protect, the evicted func will not be called for EvictReplaced.
+func (ev eviction) dispatch(protect func(func())) {
+	if ev.fn != nil && ev.reason != EvictReplaced {
+		protect(func() { ev.fn(ev.key, ev.entry) })
+	}
+	for _, h := range ev.handlers {
+		h := h
+		protect(func() { h(ev.key, ev.entry.value, ev.reason) })
+	}
+}
+
+// dispatchLoop dispatch queued evictions until the queue closed by Close.
+func (c *LocalCache) dispatchLoop() {
+	defer c.dispatcher.Done()
+	for ev := range c.evictions {
+		ev.dispatch(c.protect)
+	}
+}
+
+// OnCallbackError register a handler which will be called with the value recovered from a panic of
+// the evicted func, callbacks registered by OnEvict, OnSweep, OnHit, OnMiss, OnClose and OnThreshold,
+// Loader called by RefreshAhead in background, WriteBack called by the background flush and Sizer measuring
+// stored values, whose value is counted as 0 bytes then. Such panics are always recovered, so a bad callback
+// neither kills the goroutine calling it nor stops the other callbacks.
+//
+// Funcs passed to a call and run in the goroutine of caller, such as fn of Update and GetOrCompute, pred of
+// DeleteFunc, KeyStringer used by DeletePrefix and Sizer checking MaxValueBytes, are not covered: their panics
+// are passed to the caller, after the lock of shard released.
+func (c *LocalCache) OnCallbackError(fn func(r interface{})) {
+	c.mu.Lock()
+	c.onCallbackError = append(c.onCallbackError, fn)
+	c.mu.Unlock()
+}
+
+// protect call fn and report its panic to OnCallbackError handlers, the caller must not hold any lock.
+func (c *LocalCache) protect(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.reportPanics([]interface{}{r})
+		}
+	}()
+	fn()
+}
+
+// reportPanics call OnCallbackError handlers with recovered panics, the caller must not hold any lock.
+func (c *LocalCache) reportPanics(panics []interface{}) {
+	c.mu.RLock()
+	handlers := c.onCallbackError
+	c.mu.RUnlock()
+	for _, r := range panics {
+		for _, h := range handlers {
+			h(r)
+		}
+	}
+}
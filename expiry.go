@@ -0,0 +1,37 @@
+package localcache
+
+// expiryItem is a node of expiryHeap, it track the expire time of a key.
+type expiryItem struct {
+	key    Key
+	expire int64
+	index  int
+}
+
+// expiryHeap is a min-heap of expiryItem ordered by expire time, implement heap.Interface.
+type expiryHeap []*expiryItem
+
+func (h expiryHeap) Len() int { return len(h) }
+
+func (h expiryHeap) Less(i, j int) bool { return h[i].expire < h[j].expire }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	item := x.(*expiryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[:n-1]
+	return item
+}
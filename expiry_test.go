@@ -0,0 +1,56 @@
+package localcache
+
+import (
+	"testing"
+	"time"
+)
+
+const sweepEntries = 1000000
+
+func newSweepCache(b *testing.B) *LocalCache {
+	c := NewLocalCache(&CacheConfig{Expiration: time.Hour})
+	for i := 0; i < sweepEntries; i++ {
+		c.Set(i, i)
+	}
+	return c
+}
+
+// fillExpired add n already expired entries which will be removed by next sweep.
+func fillExpired(c *LocalCache, n int) {
+	past := time.Now().Add(-time.Second).UnixNano()
+	for i := 0; i < n; i++ {
+		key := -1 - i
+		s := c.shard(key)
+		s.set(key, Entry{value: i, expire: past})
+	}
+}
+
+func BenchmarkExpireKeys_Heap1M(b *testing.B) {
+	c := newSweepCache(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		fillExpired(c, 100)
+		b.StartTimer()
+		c.expireKeys()
+	}
+}
+
+func BenchmarkExpireKeys_Scan1M(b *testing.B) {
+	c := newSweepCache(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		fillExpired(c, 100)
+		b.StartTimer()
+		for _, s := range c.shards {
+			s.mu.Lock()
+			for key, entry := range s.data {
+				if entry.IsExpired() {
+					c.removeExpired(s, key, entry)
+				}
+			}
+			c.unlock(s)
+		}
+	}
+}
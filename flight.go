@@ -0,0 +1,83 @@
+package localcache
+
+import "sync"
+
+// call is an in-flight or completed load of a key.
+type call struct {
+	done     chan struct{}
+	value    interface{}
+	err      error
+	panicked bool
+	panic    interface{}
+	// abandoned report whether the load failed because ctx of the leader is done, or the background
+	// refresh panicked, so waiters should retry instead of sharing the result.
+	abandoned bool
+}
+
+// result return the value and error of a completed cl, or propagate the panic of its loader.
+func (cl *call) result() (interface{}, error) {
+	if cl.panicked {
+		panic(cl.panic)
+	}
+	return cl.value, cl.err
+}
+
+// flightGroup deduplicate concurrent loads of the same key, the zero value is ready to use.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[Key]*call
+}
+
+// join return the in-flight call of key or register a new one,
+// leader report whether the caller is responsible for the load and must call finish.
+func (g *flightGroup) join(key Key) (cl *call, leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if cl, ok := g.calls[key]; ok {
+		return cl, false
+	}
+	if g.calls == nil {
+		g.calls = make(map[Key]*call)
+	}
+	cl = &call{done: make(chan struct{})}
+	g.calls[key] = cl
+	return cl, true
+}
+
+// finish publish the result of cl to all waiters and remove it from group.
+func (g *flightGroup) finish(key Key, cl *call, value interface{}, err error) {
+	cl.value, cl.err = value, err
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(cl.done)
+}
+
+// do call fn once for concurrent callers of the same key and share its result,
+// a panic in fn will be propagated to all callers.
+func (g *flightGroup) do(key Key, fn func() (interface{}, error)) (interface{}, error) {
+	cl, leader := g.join(key)
+	if !leader {
+		<-cl.done
+		return cl.result()
+	}
+	return g.run(key, cl, fn)
+}
+
+// run call fn as the leader of cl and finish cl with its result, a panic in fn is recorded for the waiters
+// of cl and propagated.
+func (g *flightGroup) run(key Key, cl *call, fn func() (interface{}, error)) (interface{}, error) {
+	returned := false
+	defer func() {
+		if !returned {
+			r := recover()
+			cl.panicked, cl.panic = true, r
+			g.finish(key, cl, nil, nil)
+			panic(r)
+		}
+	}()
+	v, err := fn()
+	returned = true
+	g.finish(key, cl, v, err)
+	return v, err
+}
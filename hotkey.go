@@ -0,0 +1,80 @@
+package localcache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// KeyHits is the key and hit count of an entry returned by TopKeys.
+type KeyHits struct {
+	Key  Key
+	Hits int64
+}
+
+// keyHits is the hit counts of keys in a shard recorded if TrackKeyHits configured. Counts of known keys
+// are increased atomically under the read lock, so hammering the same keys does not contend.
+type keyHits struct {
+	mu     sync.RWMutex
+	counts map[Key]*int64
+}
+
+// add increase the hit count of key by one.
+func (h *keyHits) add(key Key) {
+	h.mu.RLock()
+	n, ok := h.counts[key]
+	if ok {
+		atomic.AddInt64(n, 1)
+	}
+	h.mu.RUnlock()
+	if ok {
+		return
+	}
+	h.mu.Lock()
+	if h.counts == nil {
+		h.counts = make(map[Key]*int64)
+	}
+	if n, ok = h.counts[key]; !ok {
+		n = new(int64)
+		h.counts[key] = n
+	}
+	atomic.AddInt64(n, 1)
+	h.mu.Unlock()
+}
+
+// TopKeys return at most n keys hit most by Get and friends, ordered by hits descending, it returns nil if
+// TrackKeyHits not configured. Keys keep counted after deleted or expired until ResetKeyHits called.
+// Counts are kept by shard under their own locks, so reading them neither blocks cache operations nor
+// affects stats and LRU recency.
+func (c *LocalCache) TopKeys(n int) []KeyHits {
+	if n <= 0 {
+		return nil
+	}
+	var top []KeyHits
+	for _, s := range c.shards {
+		s.hits.mu.RLock()
+		for key, hits := range s.hits.counts {
+			top = append(top, KeyHits{Key: key, Hits: atomic.LoadInt64(hits)})
+		}
+		s.hits.mu.RUnlock()
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Hits != top[j].Hits {
+			return top[i].Hits > top[j].Hits
+		}
+		return c.KeyString(top[i].Key) < c.KeyString(top[j].Key)
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// ResetKeyHits clear the hit counts of all keys recorded for TopKeys.
+func (c *LocalCache) ResetKeyHits() {
+	for _, s := range c.shards {
+		s.hits.mu.Lock()
+		s.hits.counts = nil
+		s.hits.mu.Unlock()
+	}
+}
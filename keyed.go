@@ -0,0 +1,60 @@
+package localcache
+
+import "sync/atomic"
+
+// GetStringKeyed will do same as Get for a string key, but the key is not boxed into interface{} while the
+// lookup can be served under the read lock, which saves an allocation on every call. It falls back to Get,
+// and boxes the key, if the key has expired or any of Sliding, TrackLatency, TrackKeyHits, CopyOnRead,
+// RefreshAhead, OnHit, OnMiss or an eviction policy promoting read entries is configured.
+func (c *LocalCache) GetStringKeyed(key string) (interface{}, error) {
+	if c.fastRead() {
+		if v, ok, err := c.getFast(c.shards[hashString(key)&c.mask], key); ok {
+			return v, err
+		}
+	}
+	return c.Get(key)
+}
+
+// GetIntKeyed will do same as GetStringKeyed for an int key.
+func (c *LocalCache) GetIntKeyed(key int) (interface{}, error) {
+	if c.fastRead() {
+		if v, ok, err := c.getFast(c.shards[mix(uint64(key))&c.mask], key); ok {
+			return v, err
+		}
+	}
+	return c.Get(key)
+}
+
+// fastRead report whether a lookup has nothing to do but read the entry and count stats,
+// so it can be served by getFast.
+func (c *LocalCache) fastRead() bool {
+	return !c.config.Sliding && !c.config.TrackLatency && !c.config.TrackKeyHits && !c.config.CopyOnRead &&
+		(c.config.RefreshAhead <= 0 || c.config.Loader == nil) &&
+		atomic.LoadInt32(&c.hasHit) == 0 && atomic.LoadInt32(&c.hasMiss) == 0 && !c.isClosed()
+}
+
+// getFast will do same as find under the read lock of s without letting key escape, ok is false if the
+// lookup must be done by Get, because s promotes read entries or the entry has expired.
+func (c *LocalCache) getFast(s *shard, key Key) (v interface{}, ok bool, err error) {
+	if s.promotes() {
+		return nil, false, nil
+	}
+	s.mu.RLock()
+	e, found := s.data[key]
+	switch {
+	case !found:
+		atomic.AddInt64(&s.stats.Misses, 1)
+		s.mu.RUnlock()
+		return nil, true, ErrNoSuchKey
+	case e.IsExpired():
+		s.mu.RUnlock()
+		return nil, false, nil
+	}
+	atomic.AddInt64(&s.stats.Hits, 1)
+	e.access()
+	s.mu.RUnlock()
+	if e.isNegative() {
+		return nil, true, e.negativeErr()
+	}
+	return e.value, true, nil
+}
@@ -1,8 +1,18 @@
 package localcache
 
 import (
+	"container/list"
+	"context"
 	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,45 +27,370 @@ var (
 	ErrDuplicateEvictedFunc = errors.New("err: re-set evicted function")
 	// ErrDuplicateKey indicate the key has already exist in cache.
 	ErrDuplicateKey = errors.New("err: duplicate key")
+	// ErrNegativeCached indicate the key has been cached as not found by SetNegative.
+	ErrNegativeCached = errors.New("err: negative cached key")
+	// ErrNilPointer indicate the value is a nil pointer which can not be dereferenced by GetXXX func.
+	ErrNilPointer = errors.New("err: nil pointer")
+	// ErrTooManyLoads indicate MaxConcurrentLoads reached and LoadFailFast configured.
+	ErrTooManyLoads = errors.New("err: too many concurrent loads")
+	// ErrValueTooLarge indicate the size of value exceed MaxValueBytes.
+	ErrValueTooLarge = errors.New("err: value too large")
+	// ErrCacheClosed indicate the cache has been closed, it is returned by Get, Add, TrySet and friends after Close.
+	ErrCacheClosed = errors.New("err: cache closed")
 )
 
+// DuplicateKeyError indicate Key has already exist in cache, it unwraps to ErrDuplicateKey.
+type DuplicateKeyError struct {
+	Key Key
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrDuplicateKey, e.Key)
+}
+
+// Unwrap return ErrDuplicateKey.
+func (e *DuplicateKeyError) Unwrap() error {
+	return ErrDuplicateKey
+}
+
 const (
 	// ExpireDuration indicate key has already expired, so set to -1.
 	ExpireDuration = time.Duration(-1)
+	// NeverExpireDuration indicate key will never expire, so set to max duration.
+	NeverExpireDuration = time.Duration(math.MaxInt64)
+
+	defaultExpiration  = time.Second * time.Duration(600)
+	defaultExpireTick  = time.Minute * time.Duration(5)
+	defaultEvictBuffer = 1024
+)
+
+// EvictReason indicate why an entry is removed or overwritten.
+type EvictReason int
 
-	defaultExpiration = time.Second * time.Duration(600)
-	defaultExpireTick = time.Minute * time.Duration(5)
+const (
+	// EvictExpired indicate the entry has expired or been expired by Expire.
+	EvictExpired EvictReason = iota
+	// EvictDeleted indicate the entry has been deleted manually, such as Delete, Flush and Reset.
+	EvictDeleted
+	// EvictReplaced indicate the value of entry has been overwritten by a new one.
+	EvictReplaced
+	// EvictCapacity indicate the entry has been evicted to make room for others.
+	EvictCapacity
 )
 
+// String return the name of reason.
+func (r EvictReason) String() string {
+	switch r {
+	case EvictExpired:
+		return "expired"
+	case EvictDeleted:
+		return "deleted"
+	case EvictReplaced:
+		return "replaced"
+	case EvictCapacity:
+		return "capacity"
+	}
+	return "unknown"
+}
+
 // Key is a generic type for map key.
 type Key interface{}
 
 // Entry is a container present data with expire info.
 type Entry struct {
-	value  interface{}
-	expire int64
+	value     interface{}
+	expire    int64
+	item      *expiryItem
+	elem      *list.Element
+	meta      *entryMeta
+	sliding   time.Duration
+	idle      int64
+	protected bool
+	version   int64
+	bytes     int64
+	tags      []string
+}
+
+// entryMeta is the metadata shared by copies of an entry, accessed is updated atomically under the read lock.
+// clock is the Clock of the cache which the entry belongs to.
+type entryMeta struct {
+	created  int64
+	accessed int64
+	clock    Clock
+}
+
+// now return the current time of the cache which entry belongs to.
+func (entry *Entry) now() time.Time {
+	if entry.meta == nil || entry.meta.clock == nil {
+		return time.Now()
+	}
+	return entry.meta.clock.Now()
+}
+
+// CreatedAt return the time when the entry has been stored.
+func (entry *Entry) CreatedAt() time.Time {
+	if entry.meta == nil {
+		return time.Time{}
+	}
+	return time.Unix(0, entry.meta.created)
+}
+
+// LastAccessedAt return the time when the entry has been read last time, or CreatedAt if never read.
+func (entry *Entry) LastAccessedAt() time.Time {
+	if entry.meta == nil {
+		return time.Time{}
+	}
+	return time.Unix(0, atomic.LoadInt64(&entry.meta.accessed))
+}
+
+// access record the entry has been read now.
+func (entry *Entry) access() {
+	if entry.meta != nil {
+		atomic.StoreInt64(&entry.meta.accessed, entry.now().UnixNano())
+	}
 }
 
-// IsExpired indicate an entry whether expired.
+// IsExpired indicate an entry whether expired, or has been idle longer than IdleTimeout.
+// The time is told by the Clock of cache which the entry belongs to.
 func (entry *Entry) IsExpired() bool {
-	return entry.expire != 0 && entry.expire < time.Now().UnixNano()
+	now := entry.now().UnixNano()
+	return entry.expire != 0 && entry.expire < now || entry.isIdle(now)
+}
+
+// isIdle report whether entry has not been read for IdleTimeout.
+func (entry *Entry) isIdle(now int64) bool {
+	return entry.idle > 0 && entry.meta != nil && atomic.LoadInt64(&entry.meta.accessed)+entry.idle < now
+}
+
+// idleLeft return the left time until entry expire by IdleTimeout, NeverExpireDuration if not configured.
+func (entry *Entry) idleLeft(now time.Time) time.Duration {
+	if entry.idle <= 0 || entry.meta == nil {
+		return NeverExpireDuration
+	}
+	return time.Duration(atomic.LoadInt64(&entry.meta.accessed) + entry.idle - now.UnixNano())
+}
+
+// negative is the value stored by SetNegative, or by GetOrLoad with the error of a failed load.
+type negative struct {
+	err error
+}
+
+// isNegative report whether entry is stored by SetNegative or a failed load.
+func (entry *Entry) isNegative() bool {
+	_, ok := entry.value.(negative)
+	return ok
+}
+
+// negativeErr return the cached error of a negative entry, ErrNegativeCached if stored by SetNegative.
+func (entry *Entry) negativeErr() error {
+	if n, _ := entry.value.(negative); n.err != nil {
+		return n.err
+	}
+	return ErrNegativeCached
+}
+
+// ttl return the left life of an entry, NeverExpireDuration if entry never expire.
+func (entry *Entry) ttl(now time.Time) time.Duration {
+	if entry.expire == 0 {
+		return NeverExpireDuration
+	}
+	return time.Duration(entry.expire - now.UnixNano())
 }
 
 // CacheStat store cache stats.
 type CacheStat struct {
+	// Entries is the number of stored entries read by Stats, it includes entries which have expired but
+	// not been removed by the sweep or lookups yet, LiveEntries count only the live ones.
 	Entries int64
+	// Bytes is the approximate size of stored values measured by Sizer, 0 if Sizer not configured.
+	Bytes   int64
 	Expired int64
+	// Flushed is the number of entries removed by Flush and FlushSilent.
+	Flushed int64
 	Hits    int64
 	Misses  int64
 	Total   int64
+	// GetCount and GetNanos are the number and cumulative latency of lookups, SetCount and SetNanos
+	// are the ones of Set and Add family, they are recorded only if TrackLatency configured.
+	GetCount int64
+	GetNanos int64
+	SetCount int64
+	SetNanos int64
+}
+
+// HitRatio return Hits/(Hits+Misses), 0 if there is no lookup.
+func (s CacheStat) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// AvgEntryBytes return Bytes/Entries, 0 if there is no entry.
+func (s CacheStat) AvgEntryBytes() float64 {
+	if s.Entries == 0 {
+		return 0
+	}
+	return float64(s.Bytes) / float64(s.Entries)
+}
+
+// MissRatio return Misses/(Hits+Misses), 0 if there is no lookup.
+func (s CacheStat) MissRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Misses) / float64(s.Hits+s.Misses)
+}
+
+// AvgGetLatency return GetNanos/GetCount, 0 if there is no lookup recorded.
+func (s CacheStat) AvgGetLatency() time.Duration {
+	if s.GetCount == 0 {
+		return 0
+	}
+	return time.Duration(s.GetNanos / s.GetCount)
+}
+
+// AvgSetLatency return SetNanos/SetCount, 0 if there is no set recorded.
+func (s CacheStat) AvgSetLatency() time.Duration {
+	if s.SetCount == 0 {
+		return 0
+	}
+	return time.Duration(s.SetNanos / s.SetCount)
+}
+
+// String return a one-line summary of stats such as "entries=10 hits=90 misses=10 hitRatio=0.90 expired=3 ...".
+func (s CacheStat) String() string {
+	return string(s.appendTo(make([]byte, 0, 128)))
+}
+
+// appendTo append the summary of stats to b.
+func (s CacheStat) appendTo(b []byte) []byte {
+	b = append(b, "entries="...)
+	b = strconv.AppendInt(b, s.Entries, 10)
+	b = append(b, " hits="...)
+	b = strconv.AppendInt(b, s.Hits, 10)
+	b = append(b, " misses="...)
+	b = strconv.AppendInt(b, s.Misses, 10)
+	b = append(b, " hitRatio="...)
+	b = strconv.AppendFloat(b, s.HitRatio(), 'f', 2, 64)
+	b = append(b, " expired="...)
+	b = strconv.AppendInt(b, s.Expired, 10)
+	b = append(b, " flushed="...)
+	b = strconv.AppendInt(b, s.Flushed, 10)
+	b = append(b, " total="...)
+	b = strconv.AppendInt(b, s.Total, 10)
+	return b
 }
 
 // CacheConfig is configuration struct for local cache.
 type CacheConfig struct {
 	Expiration time.Duration
+	// ExpireTick is the interval of background sweep which remove expired entries. A ExpireTick <= 0
+	// disable the sweep and no goroutine will be started, expired entries are only removed lazily when
+	// they are accessed, so expired entries never accessed again keep holding memory.
 	ExpireTick time.Duration
+	// Shards is the number of shards, each shard has its own lock.
+	// It will be rounded up to a power of two, default is 1.
+	Shards int
+	// ExpireJitter randomize the expiration of entries set by SetWithExpire, AddWithExpire and
+	// MSetWithExpire by ± ExpireJitter, to avoid keys set in a burst expiring at the same time.
+	ExpireJitter time.Duration
+	// AsyncEvict dispatch evicted func and OnEvict callbacks in a background goroutine,
+	// so slow callbacks will not block cache operations. Pending callbacks run before Close returns.
+	AsyncEvict bool
+	// EvictBuffer is the buffer size of async eviction queue, default is 1024. Evictions beyond a full queue
+	// are dispatched synchronously by the goroutine causing them, so a re-entrant callback never deadlocks.
+	EvictBuffer int
+	// MaxEntries limit the number of entries, 0 means unlimited. It is split evenly over shards,
+	// so with many shards a shard may evict before the whole cache is full.
+	MaxEntries int
+	// EvictionPolicy decide which entry will be evicted when MaxEntries reached, default is PolicyLRU.
+	EvictionPolicy EvictionPolicy
+	// ProtectedRatio is the ratio of MaxEntries for the protected segment of PolicySLRU, default is 0.8.
+	ProtectedRatio float64
+	// EvictionSampleSize is the number of entries sampled to choose a victim by PolicySampledLRU, default is 5.
+	// A larger sample evict closer to exact LRU at the cost of slower evictions.
+	EvictionSampleSize int
+	// EvictedFunc is the evicted func, same as calling SetEvictedFunc after creation.
+	EvictedFunc func(Key, Entry)
+	// Sliding extend the life of an entry by its expiration on every successful read, so frequently used
+	// entries stay alive. Reads take the write lock of shard to update the expiry heap, which makes
+	// concurrent reads of the same shard serialized.
+	Sliding bool
+	// MaxValueBytes reject values whose size reported by Sizer exceed it, 0 means unlimited.
+	// It takes effect only if Sizer configured. Add, Replace and Update return ErrValueTooLarge and keep
+	// the old value. Set, TrySet, MSet, SetKeepTTL and a newer SetIfNewer remove the old value of key as
+	// EvictDeleted instead, so a stale value is not served, and TrySet return ErrValueTooLarge as well.
+	MaxValueBytes int64
+	// Sizer report the approximate size of values in bytes, the total is reported as Bytes of Stats.
+	// It is called on every store of a value when configured.
+	Sizer Sizer
+	// ExpireBudget limit the number of expired entries removed from a shard by one sweep, 0 means unlimited.
+	// It bounds how long the sweep holds the lock of a shard, leftover expired entries are removed
+	// by the next sweep or lazily on access.
+	ExpireBudget int
+	// IdleTimeout expire entries which have not been read for it, regardless of their expiration, 0 means
+	// disabled. Only reads count as access, overwriting a key starts a new entry. The sweep has to scan
+	// all entries to find idle ones, which costs O(n) per tick.
+	IdleTimeout time.Duration
+	// RefreshAhead reload an entry by Loader in background when it is read within RefreshAhead of its
+	// expiration, so hot keys are refreshed before they expire. The reloaded value is stored with the
+	// default expiration, and a failed reload keeps the current value until it expires.
+	RefreshAhead time.Duration
+	// Loader load the value of key for RefreshAhead.
+	Loader func(key Key) (interface{}, error)
+	// Clock tell the time used to compute and check expiration, default is the real clock.
+	// The background sweep still runs every ExpireTick of real time, but expire entries by Clock.
+	Clock Clock
+	// EventBuffer is the buffer size of channels returned by Subscribe, default is 1024.
+	EventBuffer int
+	// EventBlock make mutations wait for subscribers whose channel is full, instead of dropping events.
+	EventBlock bool
+	// TrackLatency record the count and latency of lookups and sets in stats, measured by real time.
+	TrackLatency bool
+	// TrackKeyHits count hits of every key by Get and friends for TopKeys, it costs memory for every key ever hit.
+	TrackKeyHits bool
+	// SoftLimit evict a tenth of entries by EvictionPolicy on every sweep while the heap in use of process,
+	// reported by runtime.ReadMemStats, exceed SoftLimit bytes, 0 means disabled. Entries are chosen
+	// arbitrarily if MaxEntries not configured. It works only if the background sweep enabled.
+	SoftLimit uint64
+	// KeyStringer render keys wherever a string form is needed, such as DeletePrefix and DumpJSON,
+	// default is fmt.Sprint. DeletePrefix matches keys which are not string only if KeyStringer configured.
+	KeyStringer func(Key) string
+	// DerefPointers make GetXXX func for numbers, strings, bytes and runes dereference a pointer value once,
+	// so a stored *int can be read by GetInt64. A nil pointer get ErrNilPointer. GetError is not affected.
+	DerefPointers bool
+	// MaxConcurrentLoads limit the number of loader calls running at the same time across the cache,
+	// including GetOrCompute family, GetOrComputeMulti, ReadThrough, WarmMulti and RefreshAhead,
+	// 0 means unlimited. Loads beyond it wait for a slot, or fail with ErrTooManyLoads if LoadFailFast.
+	MaxConcurrentLoads int
+	LoadFailFast       bool
+	// CopyOnRead make every method returning values by key, such as Get family, Peek, GetStale, GetMulti,
+	// GetKeysEntry, WaitFor and GetOrCompute family, return a deep copy of value made by a round trip of
+	// encoding/gob, so callers can never modify the cached value through them. Items, DumpJSON, events and
+	// callbacks still see the cached values. It costs an encoding and decoding on every read, which is usually
+	// far slower than the lookup itself, and concrete types of values must be registered by gob.Register.
+	// Unexported fields are not copied, and the error of gob is returned if value can not be copied, while
+	// GetMulti and GetKeysEntry report such keys as missing.
+	CopyOnRead bool
+	// InitialCapacity is the number of entries the cache is sized for up front, split evenly over shards.
+	// It saves rehashing while a large cache grows, and is kept by Flush and Reset.
+	InitialCapacity int
+	// WriteBack enable write-back mode, values stored are marked dirty and passed to WriteBack in batches
+	// of WriteBackBatch every WriteBackInterval, default is 1 second. Keys are marked clean before the call
+	// and dirty again if it fails or panics. Close flush all dirty keys once more after the background goroutines stopped.
+	WriteBack         Sink
+	WriteBackInterval time.Duration
+	// WriteBackBatch limit the number of keys flushed every interval, 0 means unlimited.
+	WriteBackBatch int
+	// Codec serialize keys and values for Save and Load, default is encoding/gob of the whole snapshot.
+	// GobCodec and JSONCodec are provided.
+	Codec Codec
 }
 
+// Sizer report the approximate size of value in bytes.
+type Sizer func(value interface{}) int64
+
 // NewCacheConfig populate a default cache config.
 func NewCacheConfig() *CacheConfig {
 	return &CacheConfig{
@@ -66,11 +401,40 @@ func NewCacheConfig() *CacheConfig {
 
 // LocalCache is an in-memory struct store key-value pairs.
 type LocalCache struct {
-	data       map[Key]Entry
-	mu         sync.RWMutex
-	expiration time.Duration
-	evicted    func(key Key, value Entry)
-	stats      *CacheStat
+	config          CacheConfig
+	shards          []*shard
+	mask            uint64
+	mu              sync.RWMutex
+	expiration      time.Duration
+	jitter          time.Duration
+	clock           Clock
+	evicted         func(key Key, value Entry)
+	onEvict         []func(key Key, value interface{}, reason EvictReason)
+	onHit           []func(key Key)
+	onMiss          []func(key Key)
+	onClose         []func(items map[Key]interface{})
+	onSweep         []func(removed int, duration time.Duration)
+	onCallbackError []func(r interface{})
+	thresholds      []*threshold
+	// hasThreshold is 1 if any threshold registered, size is the number of entries of all shards.
+	// hasEvict, hasHit and hasMiss are 1 if the evicted func or callbacks of OnEvict, OnHit and OnMiss are set,
+	// so hot paths skip c.mu when there is nothing to call.
+	hasThreshold int32
+	hasEvict     int32
+	hasHit       int32
+	hasMiss      int32
+	size         int64
+	flight       flightGroup
+	calls        flightGroup
+	hub          eventHub
+	dirty        dirtySet
+	loads        chan struct{} // the semaphore of MaxConcurrentLoads
+	evictions    chan eviction
+	closed       bool
+	done         chan struct{}
+	closeOnce    sync.Once
+	sweeper      sync.WaitGroup
+	dispatcher   sync.WaitGroup
 }
 
 // ResponseEntry is a wrapper of response data.
@@ -81,196 +445,1449 @@ type ResponseEntry struct {
 
 var nilResponse = &ResponseEntry{false, nil}
 
-// NewLocalCache return a empty LocalCache.
+// NewLocalCache return a empty LocalCache, a nil config means NewCacheConfig.
 func NewLocalCache(config *CacheConfig) *LocalCache {
 	if config == nil {
-		config = NewCacheConfig()
+		return New()
 	}
+	return New(WithConfig(config))
+}
+
+func newLocalCache(config *CacheConfig) *LocalCache {
+	n := shardCount(config.Shards)
 	lc := &LocalCache{
-		data:       make(map[Key]Entry),
+		config:     *config,
+		shards:     make([]*shard, n),
+		mask:       uint64(n - 1),
 		expiration: config.Expiration,
-		stats:      &CacheStat{},
+		jitter:     config.ExpireJitter,
+		clock:      config.Clock,
+		evicted:    config.EvictedFunc,
+		done:       make(chan struct{}),
+	}
+	if lc.clock == nil {
+		lc.clock = realClock{}
+	}
+	if lc.evicted != nil {
+		lc.hasEvict = 1
 	}
-	go lc.expireLoop(config.ExpireTick)
+	for i := range lc.shards {
+		lc.shards[i] = newShard(shardCapacity(config.MaxEntries, n), config.EvictionPolicy, config.ProtectedRatio,
+			config.EvictionSampleSize)
+		lc.shards[i].idle = int64(config.IdleTimeout)
+		lc.shards[i].clock = lc.clock
+		lc.shards[i].size = &lc.size
+		lc.shards[i].sizer = config.Sizer
+		if config.InitialCapacity > 0 {
+			lc.shards[i].initial = shardCapacity(config.InitialCapacity, n)
+			lc.shards[i].data = make(map[Key]Entry, lc.shards[i].initial)
+		}
+	}
+	if config.AsyncEvict {
+		size := config.EvictBuffer
+		if size <= 0 {
+			size = defaultEvictBuffer
+		}
+		lc.evictions = make(chan eviction, size)
+		lc.dispatcher.Add(1)
+		go lc.dispatchLoop()
+	}
+	if config.ExpireTick > 0 {
+		lc.sweeper.Add(1)
+		go lc.expireLoop(config.ExpireTick)
+	}
+	if config.MaxConcurrentLoads > 0 {
+		lc.loads = make(chan struct{}, config.MaxConcurrentLoads)
+	}
+	if config.WriteBack != nil {
+		interval := config.WriteBackInterval
+		if interval <= 0 {
+			interval = defaultWriteBackInterval
+		}
+		lc.sweeper.Add(1)
+		go lc.writeBackLoop(interval)
+	}
+	return lc
+}
+
+// NewLocalCacheContext will do same as NewLocalCache, but the cache will be closed when ctx is done.
+func NewLocalCacheContext(ctx context.Context, config *CacheConfig) *LocalCache {
+	lc := NewLocalCache(config)
+	go func() {
+		select {
+		case <-ctx.Done():
+			lc.Close()
+		case <-lc.done:
+		}
+	}()
 	return lc
 }
 
+// Close stop the background goroutines, pending async evictions will be dispatched before it returns.
+// Evictions after Close will be dispatched synchronously. It is safe to call Close many times.
+//
+// Once Close is called, every method which reads or stores values by key returns ErrCacheClosed, such as Get,
+// Peek, TTL, Add, TrySet, Replace, Increment, Update, Touch, Rename and Load, or reports nothing found or
+// stored if it returns no error, such as Has, GetMulti, SetXX and SetIfNewer. Set, MSet and friends become
+// no-op, and none of them panic. Methods removing keys, such as Delete, Flush and InvalidateTag, and methods
+// inspecting the whole cache, such as Items, Stats and Save, keep working on what is left.
+func (c *LocalCache) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.sweeper.Wait()
+		if c.config.WriteBack != nil {
+			c.flushDirty(0)
+		}
+		c.mu.RLock()
+		handlers := c.onClose
+		c.mu.RUnlock()
+		if len(handlers) > 0 {
+			items := c.Items()
+			for _, fn := range handlers {
+				c.protect(func() { fn(items) })
+			}
+		}
+		c.mu.Lock()
+		c.closed = true
+		if c.evictions != nil {
+			close(c.evictions)
+		}
+		c.mu.Unlock()
+		c.dispatcher.Wait()
+		c.closeEvents()
+	})
+	return nil
+}
+
+// isClosed report whether Close has been called.
+func (c *LocalCache) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// closedErrs return ErrCacheClosed for every key, the result of batch methods called after Close.
+func closedErrs(keys []Key) map[Key]error {
+	errs := make(map[Key]error, len(keys))
+	for _, key := range keys {
+		errs[key] = ErrCacheClosed
+	}
+	return errs
+}
+
+// shard return the shard which key belongs to.
+func (c *LocalCache) shard(key Key) *shard {
+	if c.mask == 0 {
+		return c.shards[0]
+	}
+	return c.shards[hashKey(key)&c.mask]
+}
+
+// shardIndex return the index of shard which key belongs to.
+func (c *LocalCache) shardIndex(key Key) uint64 {
+	if c.mask == 0 {
+		return 0
+	}
+	return hashKey(key) & c.mask
+}
+
+// partition group keys by the shard they belong to, result is indexed by shard.
+func (c *LocalCache) partition(keys []Key) [][]Key {
+	groups := make([][]Key, len(c.shards))
+	if c.mask == 0 {
+		groups[0] = keys
+		return groups
+	}
+	for _, key := range keys {
+		i := hashKey(key) & c.mask
+		groups[i] = append(groups[i], key)
+	}
+	return groups
+}
+
 func (c *LocalCache) expireLoop(tick time.Duration) {
-	ticker := time.Tick(tick)
+	defer c.sweeper.Done()
+	t := time.NewTicker(tick)
+	defer t.Stop()
 	for {
 		select {
-		case <-ticker:
+		case <-t.C:
 			c.expireKeys()
+			c.enforceSoftLimit()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// expireKeys remove expired entries, only entries at the top of expiry heap will be touched.
+// At most ExpireBudget entries will be removed from every shard if configured.
+// OnSweep callbacks are called with the number of removed entries after all shards swept.
+func (c *LocalCache) expireKeys() {
+	start := time.Now()
+	budget := c.config.ExpireBudget
+	removed := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		now := c.clock.Now().UnixNano()
+		n := 0
+		for ; len(s.expiry) > 0 && s.expiry[0].expire < now && (budget <= 0 || n < budget); n++ {
+			key := s.expiry[0].key
+			c.removeExpired(s, key, s.data[key])
+		}
+		if s.idle > 0 {
+			for key, e := range s.data {
+				if budget > 0 && n >= budget {
+					break
+				}
+				if e.isIdle(now) {
+					c.removeExpired(s, key, e)
+					n++
+				}
+			}
+		}
+		c.unlock(s)
+		removed += n
+	}
+	c.mu.RLock()
+	handlers := c.onSweep
+	c.mu.RUnlock()
+	for _, fn := range handlers {
+		c.protect(func() { fn(removed, time.Since(start)) })
+	}
+}
+
+// SetEvictedFunc set evicted func, this must be called no more once.
+func (c *LocalCache) SetEvictedFunc(fn func(Key, Entry)) {
+	if err := c.TrySetEvictedFunc(fn); err != nil {
+		panic(err)
+	}
+}
+
+// TrySetEvictedFunc set evicted func, return ErrDuplicateEvictedFunc instead of panic if it has been set.
+func (c *LocalCache) TrySetEvictedFunc(fn func(Key, Entry)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.evicted != nil {
+		return ErrDuplicateEvictedFunc
+	}
+	c.evicted = fn
+	c.updateHasEvict()
+	return nil
+}
+
+// ReplaceEvictedFunc set evicted func whether it has been set or not, a nil fn remove it.
+// It is safe to call concurrently with evictions: every eviction use the func set when it starts,
+// so an eviction in progress may still call the old func after ReplaceEvictedFunc returns.
+func (c *LocalCache) ReplaceEvictedFunc(fn func(Key, Entry)) {
+	c.mu.Lock()
+	c.evicted = fn
+	c.updateHasEvict()
+	c.mu.Unlock()
+}
+
+// updateHasEvict set hasEvict by whether the evicted func or any OnEvict callback is set,
+// the caller must hold the write lock of c.mu.
+func (c *LocalCache) updateHasEvict() {
+	if c.evicted != nil || len(c.onEvict) > 0 {
+		atomic.StoreInt32(&c.hasEvict, 1)
+	} else {
+		atomic.StoreInt32(&c.hasEvict, 0)
+	}
+}
+
+// OnEvict register a callback which will be called with reason when an entry is removed or its value
+// is overwritten, it can be called many times and all callbacks will be called in registration order.
+func (c *LocalCache) OnEvict(fn func(key Key, value interface{}, reason EvictReason)) {
+	c.mu.Lock()
+	c.onEvict = append(c.onEvict, fn)
+	c.updateHasEvict()
+	c.mu.Unlock()
+}
+
+// OnHit register a callback which will be called with key on every cache hit, including negative cached keys.
+// Callbacks are called after the lock released in the goroutine of lookup, in registration order.
+func (c *LocalCache) OnHit(fn func(key Key)) {
+	c.mu.Lock()
+	c.onHit = append(c.onHit, fn)
+	atomic.StoreInt32(&c.hasHit, 1)
+	c.mu.Unlock()
+}
+
+// OnMiss register a callback which will be called with key on every cache miss, key not exist or expired.
+// Callbacks are called after the lock released in the goroutine of lookup, in registration order.
+func (c *LocalCache) OnMiss(fn func(key Key)) {
+	c.mu.Lock()
+	c.onMiss = append(c.onMiss, fn)
+	atomic.StoreInt32(&c.hasMiss, 1)
+	c.mu.Unlock()
+}
+
+// OnClose register a callback which will be called by Close once with the live entries left in cache,
+// after the background sweep stopped. All callbacks receive the same map, so they should not modify it.
+func (c *LocalCache) OnClose(fn func(items map[Key]interface{})) {
+	c.mu.Lock()
+	c.onClose = append(c.onClose, fn)
+	c.mu.Unlock()
+}
+
+// OnSweep register a callback which will be called after every run of the background sweep with
+// how many entries have been removed and how long the sweep took in real time, without holding any lock.
+// It helps to tune ExpireTick.
+func (c *LocalCache) OnSweep(fn func(removed int, duration time.Duration)) {
+	c.mu.Lock()
+	c.onSweep = append(c.onSweep, fn)
+	c.mu.Unlock()
+}
+
+// observe call OnHit or OnMiss callbacks of keys, the caller must not hold any lock of shards.
+func (c *LocalCache) observe(hit bool, keys ...Key) {
+	if hit && c.config.TrackKeyHits {
+		for _, key := range keys {
+			c.shard(key).hits.add(key)
+		}
+	}
+	flag := &c.hasMiss
+	if hit {
+		flag = &c.hasHit
+	}
+	if atomic.LoadInt32(flag) == 0 {
+		return
+	}
+	c.mu.RLock()
+	handlers := c.onMiss
+	if hit {
+		handlers = c.onHit
+	}
+	c.mu.RUnlock()
+	for _, key := range keys {
+		for _, fn := range handlers {
+			c.protect(func() { fn(key) })
+		}
+	}
+}
+
+// hasEvictFunc report whether the evicted func or any callback has been set.
+func (c *LocalCache) hasEvictFunc() bool {
+	return atomic.LoadInt32(&c.hasEvict) == 1
+}
+
+// evict record an eviction which will be dispatched by unlock after the lock of s released,
+// so callbacks can call back into the cache. The caller must hold the write lock of s.
+func (c *LocalCache) evict(s *shard, key Key, entry Entry, reason EvictReason) {
+	if !c.hasEvictFunc() {
+		return
+	}
+	c.mu.RLock()
+	ev := eviction{fn: c.evicted, handlers: c.onEvict, key: key, entry: entry, reason: reason}
+	c.mu.RUnlock()
+	if ev.fn == nil && len(ev.handlers) == 0 {
+		return
+	}
+	s.pending = append(s.pending, ev)
+}
+
+// unlock release the write lock of s, then dispatch evictions and publish events recorded while it was held.
+func (c *LocalCache) unlock(s *shard) {
+	pending, events, panics := s.pending, s.events, s.panics
+	s.pending, s.events, s.panics = nil, nil, nil
+	s.mu.Unlock()
+	if len(panics) > 0 {
+		c.reportPanics(panics)
+	}
+	if len(pending) > 0 {
+		c.dispatch(pending)
+	}
+	if len(events) > 0 {
+		c.publish(events)
+	}
+	c.checkThresholds()
+}
+
+// unlockShards release the write locks of shards held together, then dispatch evictions and publish events
+// recorded while they were held, so no callback runs while any of them is still locked.
+func (c *LocalCache) unlockShards(shards ...*shard) {
+	var pending []eviction
+	var events []Event
+	var panics []interface{}
+	for _, s := range shards {
+		pending, events, panics = append(pending, s.pending...), append(events, s.events...), append(panics, s.panics...)
+		s.pending, s.events, s.panics = nil, nil, nil
+		s.mu.Unlock()
+	}
+	if len(panics) > 0 {
+		c.reportPanics(panics)
+	}
+	if len(pending) > 0 {
+		c.dispatch(pending)
+	}
+	if len(events) > 0 {
+		c.publish(events)
+	}
+	c.checkThresholds()
+}
+
+// dispatch call the callbacks of evictions, or queue them if AsyncEvict configured.
+// It never blocks on a full queue while holding c.mu, the overflow is called synchronously after unlocked.
+func (c *LocalCache) dispatch(evictions []eviction) {
+	c.mu.RLock()
+	if c.evictions != nil && !c.closed {
+		queued := 0
+	queue:
+		for _, ev := range evictions {
+			select {
+			case c.evictions <- ev:
+				queued++
+			default:
+				break queue
+			}
+		}
+		evictions = evictions[queued:]
+	}
+	c.mu.RUnlock()
+	for _, ev := range evictions {
+		ev.dispatch(c.protect)
+	}
+}
+
+// store set entry of key and evict the overwritten one, or evict by policy to make room for a new key.
+// The caller must hold the write lock of s.
+func (c *LocalCache) store(s *shard, key Key, entry Entry) {
+	if old, ok := s.data[key]; ok {
+		if old.IsExpired() {
+			c.evict(s, key, old, EvictExpired)
+		} else {
+			c.evict(s, key, old, EvictReplaced)
+		}
+	} else {
+		c.ensureCapacity(s)
+	}
+	s.set(key, entry)
+	c.emit(s, key, OpSet, entry)
+}
+
+// removeExpired delete an expired entry, the caller must hold the write lock of s.
+func (c *LocalCache) removeExpired(s *shard, key Key, entry Entry) {
+	c.evict(s, key, entry, EvictExpired)
+	c.emit(s, key, OpExpire, entry)
+	s.remove(key)
+	atomic.AddInt64(&s.stats.Expired, 1)
+}
+
+// expireAt return the expire timestamp after duration from now of clock, 0 means never expire.
+// The max supported expire time is the max of UnixNano, in year 2262, a duration beyond it
+// such as NeverExpireDuration will never expire instead of overflow.
+func expireAt(clock Clock, duration time.Duration) int64 {
+	if duration <= 0 {
+		return 0
+	}
+	now := clock.Now().UnixNano()
+	if now > math.MaxInt64-int64(duration) {
+		return 0
+	}
+	return now + int64(duration)
+}
+
+// slidingOf return the sliding duration of entries expire after duration, 0 if Sliding not configured.
+func (c *LocalCache) slidingOf(duration time.Duration) time.Duration {
+	if c.config.Sliding && duration > 0 {
+		return duration
+	}
+	return 0
+}
+
+// newEntry return an entry of value expire after duration with jitter.
+func (c *LocalCache) newEntry(value interface{}, duration time.Duration) Entry {
+	return Entry{value: value, expire: c.jitterExpireAt(duration), sliding: c.slidingOf(duration)}
+}
+
+// absoluteExpireAt return the expire timestamp of at, 0 means never expire if at is zero
+// or beyond the max of UnixNano.
+func absoluteExpireAt(at time.Time) int64 {
+	if at.IsZero() || at.After(time.Unix(0, math.MaxInt64)) {
+		return 0
+	}
+	return at.UnixNano()
+}
+
+// jitterExpireAt will do same as expireAt but randomize duration by ± ExpireJitter.
+func (c *LocalCache) jitterExpireAt(duration time.Duration) int64 {
+	if duration > 0 && c.jitter > 0 && duration <= NeverExpireDuration-c.jitter {
+		duration += time.Duration(rand.Int63n(int64(2*c.jitter)+1)) - c.jitter
+		if duration <= 0 {
+			duration = 1
 		}
 	}
+	return expireAt(c.clock, duration)
+}
+
+// recordLatency add the latency of a lookup or set of key started at start to stats.
+func (c *LocalCache) recordLatency(key Key, start time.Time, set bool) {
+	s := c.shard(key)
+	d := int64(time.Since(start))
+	if set {
+		atomic.AddInt64(&s.stats.SetCount, 1)
+		atomic.AddInt64(&s.stats.SetNanos, d)
+	} else {
+		atomic.AddInt64(&s.stats.GetCount, 1)
+		atomic.AddInt64(&s.stats.GetNanos, d)
+	}
+}
+
+// tooLarge report whether size of value exceed MaxValueBytes.
+func (c *LocalCache) tooLarge(value interface{}) bool {
+	return c.config.MaxValueBytes > 0 && c.config.Sizer != nil && c.config.Sizer(value) > c.config.MaxValueBytes
+}
+
+// Add will do same as Set but return an error if key exists.
+func (c *LocalCache) Add(key Key, value interface{}) error {
+	return c.AddWithExpire(key, value, c.defaultExpiration())
+}
+
+// AddWithExpire will do same as SetWithExpire but return an error if key exists.
+func (c *LocalCache) AddWithExpire(key Key, value interface{}, duration time.Duration) error {
+	return c.addEntry(key, c.newEntry(value, duration))
+}
+
+// AddWithExpireAt will do same as SetWithExpireAt but return an error if key exists.
+func (c *LocalCache) AddWithExpireAt(key Key, value interface{}, at time.Time) error {
+	return c.addEntry(key, Entry{value: value, expire: absoluteExpireAt(at)})
+}
+
+func (c *LocalCache) addEntry(key Key, entry Entry) error {
+	if c.isClosed() {
+		return ErrCacheClosed
+	}
+	if c.config.TrackLatency {
+		defer c.recordLatency(key, time.Now(), true)
+	}
+	if c.tooLarge(entry.value) {
+		return ErrValueTooLarge
+	}
+	s := c.shard(key)
+	s.mu.Lock()
+	_, ok := s.search(key)
+	if ok {
+		c.unlock(s)
+		return ErrDuplicateKey
+	}
+	c.store(s, key, entry)
+	atomic.AddInt64(&s.stats.Total, 1)
+	c.unlock(s)
+	return nil
+}
+
+// SetDefaultExpiration change the default expiration applied by Set, Add and friends called afterwards,
+// a duration <= 0 means never expire. Stored entries keep their expiration. It is safe for concurrent use.
+func (c *LocalCache) SetDefaultExpiration(duration time.Duration) {
+	atomic.StoreInt64((*int64)(&c.expiration), int64(duration))
+}
+
+// defaultExpiration return the default expiration set by config or SetDefaultExpiration.
+func (c *LocalCache) defaultExpiration() time.Duration {
+	return time.Duration(atomic.LoadInt64((*int64)(&c.expiration)))
+}
+
+// Set set key-value with default expiration.
+func (c *LocalCache) Set(key Key, value interface{}) {
+	c.SetWithExpire(key, value, c.defaultExpiration())
+}
+
+// SetWithExpire set key-value with user setup expiration, a duration <= 0 or reaching beyond year 2262
+// (the max of UnixNano) means never expire.
+func (c *LocalCache) SetWithExpire(key Key, value interface{}, duration time.Duration) {
+	c.TrySetWithExpire(key, value, duration)
+}
+
+// TrySet will do same as Set but return ErrValueTooLarge if the value exceed MaxValueBytes,
+// or ErrCacheClosed if cache has been closed.
+func (c *LocalCache) TrySet(key Key, value interface{}) error {
+	return c.TrySetWithExpire(key, value, c.defaultExpiration())
+}
+
+// TrySetWithExpire will do same as SetWithExpire but return ErrValueTooLarge if the value exceed MaxValueBytes.
+func (c *LocalCache) TrySetWithExpire(key Key, value interface{}, duration time.Duration) error {
+	return c.setEntry(key, c.newEntry(value, duration))
+}
+
+// SetWithExpireAt set key-value which expire at the absolute time at, ExpireJitter will not be applied.
+// A zero at means never expire. A time in the past stores an already expired entry, which will never be
+// returned and will be removed as expired by the sweep, just like an entry whose life has run out.
+func (c *LocalCache) SetWithExpireAt(key Key, value interface{}, at time.Time) {
+	c.setEntry(key, Entry{value: value, expire: absoluteExpireAt(at)})
+}
+
+func (c *LocalCache) setEntry(key Key, entry Entry) error {
+	if c.isClosed() {
+		return ErrCacheClosed
+	}
+	if c.config.TrackLatency {
+		defer c.recordLatency(key, time.Now(), true)
+	}
+	if c.tooLarge(entry.value) {
+		c.Delete(key)
+		return ErrValueTooLarge
+	}
+	s := c.shard(key)
+	s.mu.Lock()
+	c.store(s, key, entry)
+	atomic.AddInt64(&s.stats.Total, 1)
+	c.unlock(s)
+	return nil
+}
+
+// SetKeepTTL set key-value and keep the expiration of the existing key, like KEEPTTL of redis.
+// An existing key which never expire stays never expire, and sliding expiration is kept as well.
+// A key not exist or has expired is stored with default expiration, same as Set.
+func (c *LocalCache) SetKeepTTL(key Key, value interface{}) {
+	if c.isClosed() {
+		return
+	}
+	if c.tooLarge(value) {
+		c.Delete(key)
+		return
+	}
+	s := c.shard(key)
+	s.mu.Lock()
+	entry := c.newEntry(value, c.defaultExpiration())
+	if e, ok := s.search(key); ok {
+		entry.expire, entry.sliding = e.expire, e.sliding
+	}
+	c.store(s, key, entry)
+	atomic.AddInt64(&s.stats.Total, 1)
+	c.unlock(s)
+}
+
+// SetIfNewer set key-value with user setup expiration only if version is greater than the version of
+// stored value, or key not exist or has expired, and report whether the value has been stored.
+// Values stored by other methods have version 0, and the version is kept by Replace and Increment.
+func (c *LocalCache) SetIfNewer(key Key, value interface{}, version int64, duration time.Duration) bool {
+	if c.isClosed() {
+		return false
+	}
+	large := c.tooLarge(value)
+	s := c.shard(key)
+	s.mu.Lock()
+	if e, ok := s.search(key); ok && version <= e.version {
+		c.unlock(s)
+		return false
+	}
+	if large {
+		c.delete(s, key)
+		c.unlock(s)
+		return false
+	}
+	entry := c.newEntry(value, duration)
+	entry.version = version
+	c.store(s, key, entry)
+	atomic.AddInt64(&s.stats.Total, 1)
+	c.unlock(s)
+	return true
+}
+
+// SetForever set key-value which never expire, ignore the default expiration.
+func (c *LocalCache) SetForever(key Key, value interface{}) {
+	c.SetWithExpire(key, value, 0)
+}
+
+// AddForever will do same as SetForever but return an error if key exists.
+func (c *LocalCache) AddForever(key Key, value interface{}) error {
+	return c.AddWithExpire(key, value, 0)
+}
+
+// SetNegative cache key as not found for duration, so Get and friends return ErrNegativeCached until it expires.
+// A negative cached key counts as a hit in stats and Has report true for it, since the result is cached.
+func (c *LocalCache) SetNegative(key Key, duration time.Duration) {
+	c.SetWithExpire(key, negative{}, duration)
+}
+
+// MSet set all key-value pairs with default expiration.
+func (c *LocalCache) MSet(items map[Key]interface{}) {
+	c.MSetWithExpire(items, c.defaultExpiration())
+}
+
+// MSetWithExpire set all key-value pairs with user setup expiration, every shard will be locked only once.
+func (c *LocalCache) MSetWithExpire(items map[Key]interface{}, duration time.Duration) {
+	if c.isClosed() {
+		return
+	}
+	keys := make([]Key, 0, len(items))
+	var large map[Key]bool
+	for key, value := range items {
+		keys = append(keys, key)
+		if c.tooLarge(value) {
+			if large == nil {
+				large = make(map[Key]bool)
+			}
+			large[key] = true
+		}
+	}
+	for i, group := range c.partition(keys) {
+		if len(group) == 0 {
+			continue
+		}
+		s := c.shards[i]
+		s.mu.Lock()
+		for _, key := range group {
+			if large[key] {
+				c.delete(s, key)
+				continue
+			}
+			c.store(s, key, c.newEntry(items[key], duration))
+			atomic.AddInt64(&s.stats.Total, 1)
+		}
+		c.unlock(s)
+	}
+}
+
+// AddAll add all key-value pairs with user setup expiration only if none of keys exists, otherwise nothing is
+// stored and a *DuplicateKeyError of the first found existing key is returned, which unwraps to ErrDuplicateKey.
+// It returns ErrValueTooLarge if any value exceed MaxValueBytes. All involved shards are locked in order
+// while checking and storing, so the batch is atomic to other writers.
+func (c *LocalCache) AddAll(items map[Key]interface{}, duration time.Duration) error {
+	if c.isClosed() {
+		return ErrCacheClosed
+	}
+	keys := make([]Key, 0, len(items))
+	for key, value := range items {
+		if c.tooLarge(value) {
+			return ErrValueTooLarge
+		}
+		keys = append(keys, key)
+	}
+	groups := c.partition(keys)
+	locked := make([]*shard, 0, len(groups))
+	for i, group := range groups {
+		if len(group) > 0 {
+			c.shards[i].mu.Lock()
+			locked = append(locked, c.shards[i])
+		}
+	}
+	defer c.unlockShards(locked...)
+	for i, group := range groups {
+		for _, key := range group {
+			if _, ok := c.shards[i].search(key); ok {
+				return &DuplicateKeyError{Key: key}
+			}
+		}
+	}
+	for i, group := range groups {
+		s := c.shards[i]
+		for _, key := range group {
+			c.store(s, key, c.newEntry(items[key], duration))
+			atomic.AddInt64(&s.stats.Total, 1)
+		}
+	}
+	return nil
+}
+
+// SetNX set key-value with user setup expiration only if key not exist or has expired,
+// and report whether the value has been stored.
+func (c *LocalCache) SetNX(key Key, value interface{}, duration time.Duration) bool {
+	return c.AddWithExpire(key, value, duration) == nil
+}
+
+// SetXX set key-value with user setup expiration only if key exists and not expired,
+// and report whether the value has been stored.
+func (c *LocalCache) SetXX(key Key, value interface{}, duration time.Duration) bool {
+	return c.ReplaceWithExpire(key, value, duration) == nil
+}
+
+// Replace update the value of an existing key and keep its expiration,
+// return ErrNoSuchKey if key not exist or ErrExpiredKey if key has expired.
+func (c *LocalCache) Replace(key Key, value interface{}) error {
+	return c.replace(key, value, false, 0)
+}
+
+// ReplaceWithExpire will do same as Replace but reset the expiration to duration.
+func (c *LocalCache) ReplaceWithExpire(key Key, value interface{}, duration time.Duration) error {
+	return c.replace(key, value, true, duration)
+}
+
+func (c *LocalCache) replace(key Key, value interface{}, reset bool, duration time.Duration) error {
+	if c.isClosed() {
+		return ErrCacheClosed
+	}
+	if c.tooLarge(value) {
+		return ErrValueTooLarge
+	}
+	s := c.shard(key)
+	s.mu.Lock()
+	e, ok := s.data[key]
+	if !ok {
+		c.unlock(s)
+		return ErrNoSuchKey
+	}
+	if e.IsExpired() {
+		c.removeExpired(s, key, e)
+		c.unlock(s)
+		return ErrExpiredKey
+	}
+	e.value = value
+	if reset {
+		e.expire = expireAt(c.clock, duration)
+		e.sliding = c.slidingOf(duration)
+	}
+	c.store(s, key, e)
+	atomic.AddInt64(&s.stats.Total, 1)
+	c.unlock(s)
+	return nil
+}
+
+// Increment add delta to the integer value associated by key and return the new value.
+// If key not exist or has expired, it will be treated as 0 and stored with default expiration.
+// The stored value must be one of int, int8, int16, int32, int64, otherwise ErrTypeMismatch returned.
+func (c *LocalCache) Increment(key Key, delta int64) (int64, error) {
+	if c.isClosed() {
+		return 0, ErrCacheClosed
+	}
+	s := c.shard(key)
+	s.mu.Lock()
+	e, ok := s.data[key]
+	if ok && e.IsExpired() {
+		c.removeExpired(s, key, e)
+		ok = false
+	}
+	if !ok {
+		c.store(s, key, Entry{value: delta, expire: expireAt(c.clock, c.defaultExpiration()), sliding: c.slidingOf(c.defaultExpiration())})
+		atomic.AddInt64(&s.stats.Total, 1)
+		c.unlock(s)
+		return delta, nil
+	}
+	var n int64
+	switch v := e.value.(type) {
+	default:
+		c.unlock(s)
+		return 0, ErrTypeMismatch
+	case int:
+		e.value = v + int(delta)
+		n = int64(v + int(delta))
+	case int8:
+		e.value = v + int8(delta)
+		n = int64(v + int8(delta))
+	case int16:
+		e.value = v + int16(delta)
+		n = int64(v + int16(delta))
+	case int32:
+		e.value = v + int32(delta)
+		n = int64(v + int32(delta))
+	case int64:
+		e.value = v + delta
+		n = v + delta
+	}
+	s.set(key, e)
+	c.emit(s, key, OpSet, e)
+	c.unlock(s)
+	return n, nil
+}
+
+// Decrement subtract delta from the integer value associated by key and return the new value.
+// It has the same semantics as Increment.
+func (c *LocalCache) Decrement(key Key, delta int64) (int64, error) {
+	return c.Increment(key, -delta)
+}
+
+// Update call fn with the value associated by key and whether it exist under the write lock, then store
+// the value returned by fn, or delete key if fn return false. An existing key keeps its expiration and a
+// missing key is stored with the default expiration. Deleting a key calls the evicted func.
+// It returns ErrValueTooLarge if the new value exceed MaxValueBytes and keep the old one.
+// fn is called while holding the lock, so it must not call back into the cache. A panic of fn is passed
+// to the caller after the lock released, and nothing is stored.
+func (c *LocalCache) Update(key Key, fn func(old interface{}, found bool) (interface{}, bool)) error {
+	if c.isClosed() {
+		return ErrCacheClosed
+	}
+	s := c.shard(key)
+	s.mu.Lock()
+	e, ok := s.data[key]
+	if ok && e.IsExpired() {
+		c.removeExpired(s, key, e)
+		ok = false
+	}
+	found := ok && !e.isNegative()
+	var old interface{}
+	if found {
+		old = e.value
+	}
+	// the lock is released if fn or Sizer panics, then the panic goes on to the caller.
+	returned := false
+	defer func() {
+		if !returned {
+			c.unlock(s)
+		}
+	}()
+	v, keep := fn(old, found)
+	large := keep && c.tooLarge(v)
+	returned = true
+	switch {
+	case !keep:
+		if found {
+			c.delete(s, key)
+		}
+	case large:
+		c.unlock(s)
+		return ErrValueTooLarge
+	case found:
+		e.value = v
+		s.set(key, e)
+		c.emit(s, key, OpSet, e)
+	default:
+		c.store(s, key, Entry{value: v, expire: expireAt(c.clock, c.defaultExpiration()), sliding: c.slidingOf(c.defaultExpiration())})
+		atomic.AddInt64(&s.stats.Total, 1)
+	}
+	c.unlock(s)
+	return nil
+}
+
+// Touch reset the expiration of key to now+duration without re-storing the value.
+// A duration <= 0 makes the key never expire.
+func (c *LocalCache) Touch(key Key, duration time.Duration) error {
+	if c.isClosed() {
+		return ErrCacheClosed
+	}
+	s := c.shard(key)
+	s.mu.Lock()
+	e, ok := s.data[key]
+	if !ok {
+		c.unlock(s)
+		return ErrNoSuchKey
+	}
+	if e.IsExpired() {
+		c.removeExpired(s, key, e)
+		c.unlock(s)
+		return ErrExpiredKey
+	}
+	e.expire = expireAt(c.clock, duration)
+	e.sliding = c.slidingOf(duration)
+	s.set(key, e)
+	c.unlock(s)
+	return nil
+}
+
+// TouchMulti will do same as Touch for all keys with every shard locked once. Errors of keys not exist
+// or have expired are collected by key, nil if all keys are touched.
+func (c *LocalCache) TouchMulti(keys []Key, duration time.Duration) map[Key]error {
+	if c.isClosed() {
+		return closedErrs(keys)
+	}
+	var errs map[Key]error
+	for i, group := range c.partition(keys) {
+		if len(group) == 0 {
+			continue
+		}
+		s := c.shards[i]
+		s.mu.Lock()
+		for _, key := range group {
+			err := ErrNoSuchKey
+			if e, ok := s.data[key]; ok && e.IsExpired() {
+				c.removeExpired(s, key, e)
+				err = ErrExpiredKey
+			} else if ok {
+				e.expire = expireAt(c.clock, duration)
+				e.sliding = c.slidingOf(duration)
+				s.set(key, e)
+				continue
+			}
+			if errs == nil {
+				errs = make(map[Key]error)
+			}
+			errs[key] = err
+		}
+		c.unlock(s)
+	}
+	return errs
+}
+
+// Rename move the value and remaining life of oldKey to newKey atomically, an existing newKey will be
+// overwritten and evicted as replaced. It returns ErrNoSuchKey if oldKey not exist or ErrExpiredKey if
+// oldKey has expired. The evicted func will not be called for oldKey since its value is moved.
+func (c *LocalCache) Rename(oldKey, newKey Key) error {
+	if c.isClosed() {
+		return ErrCacheClosed
+	}
+	i, j := c.shardIndex(oldKey), c.shardIndex(newKey)
+	src, dst := c.shards[i], c.shards[j]
+	switch {
+	case i < j:
+		src.mu.Lock()
+		dst.mu.Lock()
+	case i > j:
+		dst.mu.Lock()
+		src.mu.Lock()
+	default:
+		src.mu.Lock()
+	}
+	defer func() {
+		if i != j {
+			c.unlockShards(src, dst)
+		} else {
+			c.unlock(src)
+		}
+	}()
+	e, ok := src.data[oldKey]
+	if !ok {
+		return ErrNoSuchKey
+	}
+	if e.IsExpired() {
+		c.removeExpired(src, oldKey, e)
+		return ErrExpiredKey
+	}
+	if oldKey == newKey {
+		return nil
+	}
+	c.emit(src, oldKey, OpDelete, e)
+	src.remove(oldKey)
+	c.store(dst, newKey, Entry{value: e.value, expire: e.expire, meta: e.meta, sliding: e.sliding, version: e.version, tags: e.tags})
+	atomic.AddInt64(&dst.stats.Total, 1)
+	return nil
+}
+
+// live find a not expired entry associated by key, it will not affect stats and evicted func.
+func (c *LocalCache) live(key Key) (Entry, bool) {
+	s := c.shard(key)
+	s.mu.RLock()
+	e, ok := s.search(key)
+	s.mu.RUnlock()
+	return e, ok
+}
+
+// Has report whether key exists and not expired, it will not affect stats and evicted func.
+func (c *LocalCache) Has(key Key) bool {
+	if c.isClosed() {
+		return false
+	}
+	_, ok := c.live(key)
+	return ok
+}
+
+// lookup find a live entry associated by key, an expired entry will be removed lazily.
+func (c *LocalCache) lookup(key Key) (Entry, error) {
+	if c.isClosed() {
+		return Entry{}, ErrCacheClosed
+	}
+	if c.config.TrackLatency {
+		defer c.recordLatency(key, time.Now(), false)
+	}
+	e, err := c.find(key)
+	c.observe(err != ErrNoSuchKey && err != ErrExpiredKey, key)
+	if err == nil {
+		c.refreshAhead(key, e)
+		e.value, err = c.copyOut(e.value)
+	}
+	return e, err
+}
+
+// find will do same as lookup but not call OnHit and OnMiss callbacks.
+func (c *LocalCache) find(key Key) (Entry, error) {
+	s := c.shard(key)
+	if c.config.Sliding || s.promotes() {
+		return c.lookupPromote(s, key)
+	}
+	s.mu.RLock()
+	e, ok := s.data[key]
+	if !ok {
+		atomic.AddInt64(&s.stats.Misses, 1)
+		s.mu.RUnlock()
+		return Entry{}, ErrNoSuchKey
+	}
+	if !e.IsExpired() {
+		atomic.AddInt64(&s.stats.Hits, 1)
+		e.access()
+		s.mu.RUnlock()
+		if e.isNegative() {
+			return Entry{}, e.negativeErr()
+		}
+		return e, nil
+	}
+	s.mu.RUnlock()
+	s.mu.Lock()
+	if e, ok := s.data[key]; ok && e.IsExpired() {
+		c.removeExpired(s, key, e)
+	}
+	atomic.AddInt64(&s.stats.Misses, 1)
+	c.unlock(s)
+	return Entry{}, ErrExpiredKey
+}
+
+// lookupPromote will do same as lookup but mark the entry as recently used and extend its sliding expiration
+// under the write lock.
+func (c *LocalCache) lookupPromote(s *shard, key Key) (Entry, error) {
+	s.mu.Lock()
+	e, ok := s.data[key]
+	if !ok {
+		atomic.AddInt64(&s.stats.Misses, 1)
+		c.unlock(s)
+		return Entry{}, ErrNoSuchKey
+	}
+	if e.IsExpired() {
+		c.removeExpired(s, key, e)
+		atomic.AddInt64(&s.stats.Misses, 1)
+		c.unlock(s)
+		return Entry{}, ErrExpiredKey
+	}
+	e = s.promote(key, e)
+	e = s.slide(key, e)
+	atomic.AddInt64(&s.stats.Hits, 1)
+	e.access()
+	c.unlock(s)
+	if e.isNegative() {
+		return Entry{}, e.negativeErr()
+	}
+	return e, nil
+}
+
+// Get get the value associated by a key or an error.
+// A stored nil value is returned as (nil, nil), which is distinguished from a missing key by the error.
+func (c *LocalCache) Get(key Key) (v interface{}, err error) {
+	v, _, err = c.GetWithExpire(key)
+	return
+}
+
+// GetOK get the value associated by a key and report whether the key exists and not expired.
+func (c *LocalCache) GetOK(key Key) (v interface{}, ok bool) {
+	v, err := c.Get(key)
+	return v, err == nil
+}
+
+// GetWithExpire get the value and left life associated by a key or an error.
+// NeverExpireDuration returned as left life if the key never expire,
+// the left life include the random part if ExpireJitter configured.
+func (c *LocalCache) GetWithExpire(key Key) (v interface{}, expire time.Duration, err error) {
+	e, err := c.lookup(key)
+	if err != nil {
+		return nil, ExpireDuration, err
+	}
+	return e.value, e.ttl(c.clock.Now()), nil
+}
+
+// GetWithExpireIdle will do same as GetWithExpire but also return the left time until the entry expire by
+// IdleTimeout, which is measured after this read renewed it. NeverExpireDuration returned as idleLeft if
+// IdleTimeout not configured. The entry expire by whichever of ttl and idleLeft is closer.
+func (c *LocalCache) GetWithExpireIdle(key Key) (v interface{}, ttl, idleLeft time.Duration, err error) {
+	e, err := c.lookup(key)
+	if err != nil {
+		return nil, ExpireDuration, ExpireDuration, err
+	}
+	now := c.clock.Now()
+	return e.value, e.ttl(now), e.idleLeft(now), nil
+}
+
+// Peek get the value associated by a key or an error, without affecting stats and LRU recency.
+// An expired key will be reported by ErrExpiredKey but not removed.
+func (c *LocalCache) Peek(key Key) (v interface{}, err error) {
+	if c.isClosed() {
+		return nil, ErrCacheClosed
+	}
+	s := c.shard(key)
+	s.mu.RLock()
+	e, ok := s.data[key]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNoSuchKey
+	}
+	if e.IsExpired() {
+		return nil, ErrExpiredKey
+	}
+	if e.isNegative() {
+		return nil, e.negativeErr()
+	}
+	return c.copyOut(e.value)
 }
 
-func (c *LocalCache) expireKeys() {
-	c.mu.Lock()
-	for key, entry := range c.data {
-		if entry.IsExpired() {
-			delete(c.data, key)
-			if c.evicted != nil {
-				c.evicted(key, entry)
-			}
+// GetStale will do same as Get for a live key, but return the value of an expired entry which has not been
+// removed by the sweep yet with stale true, instead of removing it. It returns ErrNoSuchKey only if key not
+// exist, which makes stale-while-revalidate possible when the source of values is unavailable.
+func (c *LocalCache) GetStale(key Key) (v interface{}, stale bool, err error) {
+	if c.isClosed() {
+		return nil, false, ErrCacheClosed
+	}
+	s := c.shard(key)
+	s.mu.RLock()
+	e, ok := s.data[key]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, false, ErrNoSuchKey
+	}
+	if !e.IsExpired() {
+		// an entry expired since read above has been removed by lookup, its value is returned as stale.
+		live, err := c.lookup(key)
+		if err == nil {
+			return live.value, false, nil
+		}
+		if err != ErrExpiredKey {
+			return nil, false, err
 		}
 	}
-	c.mu.Unlock()
-}
-
-// SetEvictedFunc set evicted func, this must be called no more once.
-func (c *LocalCache) SetEvictedFunc(fn func(Key, Entry)) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.evicted != nil {
-		panic(ErrDuplicateEvictedFunc)
+	if e.isNegative() {
+		return nil, true, e.negativeErr()
 	}
-	c.evicted = fn
+	v, err = c.copyOut(e.value)
+	return v, err == nil, err
 }
 
-func (c *LocalCache) search(key Key) (entry Entry, ok bool) {
-	if entry, ok := c.data[key]; ok {
-		if !entry.IsExpired() {
-			return entry, true
-		}
+// GetAndDelete get the value associated by a key and remove it from cache atomically.
+// The evicted func will not be called for the removed entry since the value is handed to caller,
+// but it still will be called if the key has expired.
+func (c *LocalCache) GetAndDelete(key Key) (v interface{}, err error) {
+	v, err = c.getAndDelete(key)
+	if err != ErrCacheClosed {
+		c.observe(err != ErrNoSuchKey && err != ErrExpiredKey, key)
 	}
 	return
 }
 
-// Add will do same as Set but return an error if key exists.
-func (c *LocalCache) Add(key Key, value interface{}) error {
-	return c.AddWithExpire(key, value, c.expiration)
+func (c *LocalCache) getAndDelete(key Key) (v interface{}, err error) {
+	if c.isClosed() {
+		return nil, ErrCacheClosed
+	}
+	s := c.shard(key)
+	s.mu.Lock()
+	if e, ok := s.data[key]; ok {
+		if !e.IsExpired() {
+			c.emit(s, key, OpDelete, e)
+			s.remove(key)
+			atomic.AddInt64(&s.stats.Hits, 1)
+			c.unlock(s)
+			if e.isNegative() {
+				return nil, e.negativeErr()
+			}
+			return e.value, nil
+		}
+		c.removeExpired(s, key, e)
+		atomic.AddInt64(&s.stats.Misses, 1)
+		c.unlock(s)
+		return nil, ErrExpiredKey
+	}
+	atomic.AddInt64(&s.stats.Misses, 1)
+	c.unlock(s)
+	return nil, ErrNoSuchKey
 }
 
-// AddWithExpire will do same as SetWithExpire but return an error if key exists.
-func (c *LocalCache) AddWithExpire(key Key, value interface{}, duration time.Duration) error {
-	c.mu.Lock()
-	if c.data == nil {
-		c.data = make(map[Key]Entry)
+// EntryInfo get the time when the entry associated by key has been stored, read last time and will expire,
+// expireAt is zero if the key never expire. It will not affect stats and access time.
+func (c *LocalCache) EntryInfo(key Key) (created, accessed, expireAt time.Time, err error) {
+	if c.isClosed() {
+		return created, accessed, expireAt, ErrCacheClosed
 	}
-	_, ok := c.search(key)
-	if ok {
-		c.mu.Unlock()
-		return ErrDuplicateKey
+	s := c.shard(key)
+	s.mu.RLock()
+	e, ok := s.data[key]
+	s.mu.RUnlock()
+	if !ok {
+		return created, accessed, expireAt, ErrNoSuchKey
 	}
-	var e int64
-	if duration > 0 {
-		e = time.Now().Add(duration).UnixNano()
+	if e.IsExpired() {
+		return created, accessed, expireAt, ErrExpiredKey
 	}
-	c.data[key] = Entry{value: value, expire: e}
-	c.stats.Entries++
-	c.stats.Total++
-	c.mu.Unlock()
-	return nil
-}
-
-// Set set key-value with default expiration.
-func (c *LocalCache) Set(key Key, value interface{}) {
-	c.SetWithExpire(key, value, c.expiration)
+	if e.expire != 0 {
+		expireAt = time.Unix(0, e.expire)
+	}
+	return e.CreatedAt(), e.LastAccessedAt(), expireAt, nil
 }
 
-// SetWithExpire set key-value with user setup expiration.
-func (c *LocalCache) SetWithExpire(key Key, value interface{}, duration time.Duration) {
-	c.mu.Lock()
-	if c.data == nil {
-		c.data = make(map[Key]Entry)
+// TTL get the left life associated by a key or an error, it will not affect stats.
+// NeverExpireDuration returned if the key never expire.
+func (c *LocalCache) TTL(key Key) (time.Duration, error) {
+	if c.isClosed() {
+		return ExpireDuration, ErrCacheClosed
 	}
-	var e int64
-	if duration > 0 {
-		e = time.Now().Add(duration).UnixNano()
+	s := c.shard(key)
+	s.mu.RLock()
+	e, ok := s.data[key]
+	s.mu.RUnlock()
+	if !ok {
+		return ExpireDuration, ErrNoSuchKey
 	}
-	c.data[key] = Entry{value: value, expire: e}
-	c.stats.Entries++
-	c.stats.Total++
-	c.mu.Unlock()
-}
-
-// Get get the value associated by a key or an error.
-func (c *LocalCache) Get(key Key) (v interface{}, err error) {
-	v, _, err = c.GetWithExpire(key)
-	return
+	if e.IsExpired() {
+		return ExpireDuration, ErrExpiredKey
+	}
+	return e.ttl(c.clock.Now()), nil
 }
 
-// GetWithExpire get the value and left life associated by a key or an error.
-func (c *LocalCache) GetWithExpire(key Key) (v interface{}, expire time.Duration, err error) {
-	c.mu.RLock()
-	if e, ok := c.data[key]; ok {
-		now := time.Now()
-		if !e.IsExpired() {
-			c.stats.Hits++
-			c.mu.RUnlock()
-			return e.value, time.Duration(e.expire - now.UnixNano()), nil
+// TTLMulti get the left life of keys with every shard read locked once, NeverExpireDuration for keys never
+// expire and ExpireDuration for keys not exist or have expired. It will not affect stats and expired
+// entries are not removed.
+func (c *LocalCache) TTLMulti(keys []Key) map[Key]time.Duration {
+	ttls := make(map[Key]time.Duration, len(keys))
+	if c.isClosed() {
+		for _, key := range keys {
+			ttls[key] = ExpireDuration
 		}
-		if c.evicted != nil {
-			c.evicted(key, e)
+		return ttls
+	}
+	now := c.clock.Now()
+	for i, group := range c.partition(keys) {
+		if len(group) == 0 {
+			continue
 		}
-		delete(c.data, key)
-		c.stats.Entries--
-		c.stats.Expired++
-		c.stats.Misses++
-		c.mu.RUnlock()
-		return nil, ExpireDuration, ErrExpiredKey
+		s := c.shards[i]
+		s.mu.RLock()
+		for _, key := range group {
+			if e, ok := s.data[key]; ok && !e.IsExpired() {
+				ttls[key] = e.ttl(now)
+			} else {
+				ttls[key] = ExpireDuration
+			}
+		}
+		s.mu.RUnlock()
 	}
-	c.stats.Misses++
-	c.mu.RUnlock()
-	return nil, ExpireDuration, ErrNoSuchKey
+	return ttls
 }
 
 // GetEntry get a response entry which explain usability of the value or an error.
 func (c *LocalCache) GetEntry(key Key) (v *ResponseEntry, err error) {
-	c.mu.RLock()
-	if e, ok := c.data[key]; ok {
-		if !e.IsExpired() {
-			c.stats.Hits++
-			c.mu.RUnlock()
-			return &ResponseEntry{true, e.value}, nil
+	e, err := c.lookup(key)
+	if err != nil {
+		return nilResponse, err
+	}
+	return &ResponseEntry{true, e.value}, nil
+}
+
+// GetEntryWithExpire will do same as GetEntry but also return the left life like GetWithExpire.
+func (c *LocalCache) GetEntryWithExpire(key Key) (v *ResponseEntry, expire time.Duration, err error) {
+	e, err := c.lookup(key)
+	if err != nil {
+		return nilResponse, ExpireDuration, err
+	}
+	return &ResponseEntry{true, e.value}, e.ttl(c.clock.Now()), nil
+}
+
+// lookupKeys find live entries of keys with every shard locked once, expired entries will be removed lazily.
+// Shards whose reads change no eviction order take the read lock, and the write lock only if expired entries
+// found. fn is called for every key with the live entry or ok false, while holding the lock.
+// OnHit and OnMiss callbacks are called after all shards unlocked. Nothing is found once cache has been closed.
+func (c *LocalCache) lookupKeys(keys []Key, fn func(key Key, e Entry, ok bool)) {
+	if c.isClosed() {
+		return
+	}
+	var hits, misses []Key
+	defer func() {
+		c.observe(true, hits...)
+		c.observe(false, misses...)
+	}()
+	record := func(s *shard, key Key, e Entry, ok bool) {
+		if ok {
+			atomic.AddInt64(&s.stats.Hits, 1)
+			e.access()
+			hits = append(hits, key)
+		} else {
+			atomic.AddInt64(&s.stats.Misses, 1)
+			misses = append(misses, key)
+		}
+		fn(key, e, ok)
+	}
+	for i, group := range c.partition(keys) {
+		if len(group) == 0 {
+			continue
+		}
+		s := c.shards[i]
+		if !c.config.Sliding && !s.promotes() {
+			// reads change nothing but expired entries, so live ones are handled under the read lock,
+			// and the write lock is taken only if there are expired entries to remove.
+			var expired []Key
+			s.mu.RLock()
+			for _, key := range group {
+				e, ok := s.data[key]
+				if ok && e.IsExpired() {
+					expired = append(expired, key)
+					continue
+				}
+				record(s, key, e, ok)
+			}
+			s.mu.RUnlock()
+			if len(expired) == 0 {
+				continue
+			}
+			group = expired
 		}
-		if c.evicted != nil {
-			c.evicted(key, e)
+		s.mu.Lock()
+		for _, key := range group {
+			e, ok := s.data[key]
+			if ok && e.IsExpired() {
+				c.removeExpired(s, key, e)
+				ok = false
+			}
+			if ok {
+				e = s.promote(key, e)
+				e = s.slide(key, e)
+			}
+			record(s, key, e, ok)
 		}
-		delete(c.data, key)
-		c.stats.Entries--
-		c.stats.Expired++
-		c.stats.Misses++
-		c.mu.RUnlock()
-		return nilResponse, ErrExpiredKey
+		c.unlock(s)
 	}
-	c.stats.Misses++
-	c.mu.RUnlock()
-	return nilResponse, ErrNoSuchKey
 }
 
 // GetKeysEntry get a map of Key-ResponseEntry which explain usability of the value.
 func (c *LocalCache) GetKeysEntry(keys []Key) (v map[Key]*ResponseEntry) {
 	v = make(map[Key]*ResponseEntry)
-	c.mu.Lock()
-	for _, key := range keys {
-		if e, ok := c.data[key]; ok {
-			if !e.IsExpired() {
-				c.stats.Hits++
-				v[key] = &ResponseEntry{Valid: true, Value: e.value}
-			} else {
-				c.stats.Entries--
-				c.stats.Expired++
-				if c.evicted != nil {
-					c.evicted(key, e)
+	c.lookupKeys(keys, func(key Key, e Entry, ok bool) {
+		if ok && !e.isNegative() {
+			v[key] = &ResponseEntry{Valid: true, Value: e.value}
+		} else {
+			v[key] = nilResponse
+		}
+	})
+	if c.config.CopyOnRead {
+		for key, entry := range v {
+			if entry.Valid {
+				if value, err := c.copyOut(entry.Value); err == nil {
+					v[key] = &ResponseEntry{Valid: true, Value: value}
+				} else {
+					v[key] = nilResponse
 				}
-				delete(c.data, key)
-				v[key] = nilResponse
-				c.stats.Misses++
+			}
+		}
+	}
+	return
+}
+
+// GetMulti get values of live keys in found, and keys not exist or expired in missing.
+// Negative cached keys are in neither of them, since they are known not found.
+func (c *LocalCache) GetMulti(keys []Key) (found map[Key]interface{}, missing []Key) {
+	found = make(map[Key]interface{})
+	c.lookupKeys(keys, func(key Key, e Entry, ok bool) {
+		if ok {
+			if !e.isNegative() {
+				found[key] = e.value
 			}
 		} else {
-			c.stats.Misses++
-			v[key] = nilResponse
+			missing = append(missing, key)
+		}
+	})
+	if c.config.CopyOnRead {
+		for key, value := range found {
+			if value, err := c.copyOut(value); err == nil {
+				found[key] = value
+			} else {
+				delete(found, key)
+				missing = append(missing, key)
+			}
 		}
 	}
-	c.mu.Unlock()
 	return
 }
 
+// getValue get the value for GetXXX func, a pointer value is dereferenced once if DerefPointers configured.
+func (c *LocalCache) getValue(key Key) (interface{}, error) {
+	v, err := c.Get(key)
+	if err != nil || !c.config.DerefPointers {
+		return v, err
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, ErrNilPointer
+		}
+		return rv.Elem().Interface(), nil
+	}
+	return v, nil
+}
+
 // GetBool get bool value associated by key or an error.
 func (c *LocalCache) GetBool(key Key) (v bool, err error) {
-	e, err := c.Get(key)
+	e, err := c.getValue(key)
 	if err != nil {
 		return false, err
 	}
@@ -284,7 +1901,7 @@ func (c *LocalCache) GetBool(key Key) (v bool, err error) {
 
 // GetInt64 get int64 value associated by key or an error.
 func (c *LocalCache) GetInt64(key Key) (v int64, err error) {
-	e, err := c.Get(key)
+	e, err := c.getValue(key)
 	if err != nil {
 		return 0, err
 	}
@@ -306,7 +1923,7 @@ func (c *LocalCache) GetInt64(key Key) (v int64, err error) {
 
 // GetUint64 get uint64 value associated by key or an error.
 func (c *LocalCache) GetUint64(key Key) (v uint64, err error) {
-	e, err := c.Get(key)
+	e, err := c.getValue(key)
 	if err != nil {
 		return 0, err
 	}
@@ -328,23 +1945,56 @@ func (c *LocalCache) GetUint64(key Key) (v uint64, err error) {
 
 // GetFloat64 get float64 value associated by key or an error.
 func (c *LocalCache) GetFloat64(key Key) (v float64, err error) {
-	e, err := c.Get(key)
+	e, err := c.getValue(key)
 	if err != nil {
 		return 0, err
 	}
 	switch e.(type) {
 	default:
 	case float32:
-		return float64(e.(float64)), nil
+		return float64(e.(float32)), nil
 	case float64:
 		return e.(float64), nil
 	}
 	return 0, ErrTypeMismatch
 }
 
+// GetFloat32 get float32 value associated by key or an error.
+// A float64 value will be narrowed to float32 and may lose precision.
+func (c *LocalCache) GetFloat32(key Key) (v float32, err error) {
+	e, err := c.getValue(key)
+	if err != nil {
+		return 0, err
+	}
+	switch e.(type) {
+	default:
+	case float32:
+		return e.(float32), nil
+	case float64:
+		return float32(e.(float64)), nil
+	}
+	return 0, ErrTypeMismatch
+}
+
+// GetComplex128 get complex128 value associated by key or an error.
+func (c *LocalCache) GetComplex128(key Key) (v complex128, err error) {
+	e, err := c.getValue(key)
+	if err != nil {
+		return 0, err
+	}
+	switch e.(type) {
+	default:
+	case complex64:
+		return complex128(e.(complex64)), nil
+	case complex128:
+		return e.(complex128), nil
+	}
+	return 0, ErrTypeMismatch
+}
+
 // GetString get string value associated by key or an error.
 func (c *LocalCache) GetString(key Key) (v string, err error) {
-	e, err := c.Get(key)
+	e, err := c.getValue(key)
 	if err != nil {
 		return "", err
 	}
@@ -358,9 +2008,27 @@ func (c *LocalCache) GetString(key Key) (v string, err error) {
 	return "", ErrTypeMismatch
 }
 
+// GetBytes get []byte value associated by key or an error, a stored []byte will be returned without copy.
+func (c *LocalCache) GetBytes(key Key) (v []byte, err error) {
+	e, err := c.getValue(key)
+	if err != nil {
+		return nil, err
+	}
+	switch e.(type) {
+	default:
+	case []byte:
+		return e.([]byte), nil
+	case string:
+		return []byte(e.(string)), nil
+	}
+	return nil, ErrTypeMismatch
+}
+
 // GetByte get byte value associated by key or an error.
+// Accepted source types are byte, int8 (reinterpreted as is), int and uint, an int or uint
+// out of range 0-255 get ErrTypeMismatch instead of being truncated.
 func (c *LocalCache) GetByte(key Key) (v byte, err error) {
-	e, err := c.Get(key)
+	e, err := c.getValue(key)
 	if err != nil {
 		return 0, err
 	}
@@ -370,13 +2038,38 @@ func (c *LocalCache) GetByte(key Key) (v byte, err error) {
 		return e.(byte), nil
 	case int8:
 		return byte(e.(int8)), nil
+	case int:
+		if n := e.(int); n >= 0 && n <= math.MaxUint8 {
+			return byte(n), nil
+		}
+	case uint:
+		if n := e.(uint); n <= math.MaxUint8 {
+			return byte(n), nil
+		}
 	}
 	return 0, ErrTypeMismatch
 }
 
+// GetError get error value associated by key, err is the error of cache operation such as ErrNoSuchKey.
+// A nil value is returned as a nil error, so storing a nil error round-trips too.
+func (c *LocalCache) GetError(key Key) (v error, err error) {
+	e, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	switch e.(type) {
+	default:
+	case nil:
+		return nil, nil
+	case error:
+		return e.(error), nil
+	}
+	return nil, ErrTypeMismatch
+}
+
 // GetRune get rune value associated by key or an error.
 func (c *LocalCache) GetRune(key Key) (v rune, err error) {
-	e, err := c.Get(key)
+	e, err := c.getValue(key)
 	if err != nil {
 		return 0, err
 	}
@@ -388,52 +2081,353 @@ func (c *LocalCache) GetRune(key Key) (v rune, err error) {
 	return 0, ErrTypeMismatch
 }
 
+// GetAs get value associated by key as type T or an error, ErrTypeMismatch returned if the value is not exactly T.
+// Unlike GetInt64 and friends, no numeric conversion will be done.
+func GetAs[T any](c *LocalCache, key Key) (v T, err error) {
+	e, err := c.Get(key)
+	if err != nil {
+		return v, err
+	}
+	t, ok := e.(T)
+	if !ok {
+		return v, ErrTypeMismatch
+	}
+	return t, nil
+}
+
+// GetOrDefault get the value associated by key, or def if key not exist, expired or negative cached.
+func (c *LocalCache) GetOrDefault(key Key, def interface{}) interface{} {
+	if v, err := c.Get(key); err == nil {
+		return v
+	}
+	return def
+}
+
+// GetBoolOr get bool value associated by key, or def if GetBool return an error.
+func (c *LocalCache) GetBoolOr(key Key, def bool) bool {
+	if v, err := c.GetBool(key); err == nil {
+		return v
+	}
+	return def
+}
+
+// GetInt64Or get int64 value associated by key, or def if GetInt64 return an error.
+func (c *LocalCache) GetInt64Or(key Key, def int64) int64 {
+	if v, err := c.GetInt64(key); err == nil {
+		return v
+	}
+	return def
+}
+
+// GetFloat64Or get float64 value associated by key, or def if GetFloat64 return an error.
+func (c *LocalCache) GetFloat64Or(key Key, def float64) float64 {
+	if v, err := c.GetFloat64(key); err == nil {
+		return v
+	}
+	return def
+}
+
+// GetStringOr get string value associated by key, or def if GetString return an error.
+func (c *LocalCache) GetStringOr(key Key, def string) string {
+	if v, err := c.GetString(key); err == nil {
+		return v
+	}
+	return def
+}
+
 // Expire to expire a key immediately, ignore the default and left expiration.
 func (c *LocalCache) Expire(key Key) (err error) {
-	c.mu.Lock()
-	if e, ok := c.data[key]; ok {
-		if c.evicted != nil {
-			c.evicted(key, e)
-		}
-		delete(c.data, key)
-		c.stats.Entries--
-		c.stats.Expired++
+	s := c.shard(key)
+	s.mu.Lock()
+	if e, ok := s.data[key]; ok {
+		c.removeExpired(s, key, e)
 	}
-	c.mu.Unlock()
+	c.unlock(s)
 	return
 }
 
+// delete remove a live entry and report whether it has been removed, the caller must hold the write lock of s.
+func (c *LocalCache) delete(s *shard, key Key) bool {
+	e, ok := s.data[key]
+	if !ok {
+		return false
+	}
+	if e.IsExpired() {
+		c.removeExpired(s, key, e)
+		return false
+	}
+	c.evict(s, key, e, EvictDeleted)
+	c.emit(s, key, OpDelete, e)
+	s.remove(key)
+	return true
+}
+
+// Delete remove key from cache and report whether a live entry has been removed.
+func (c *LocalCache) Delete(key Key) bool {
+	s := c.shard(key)
+	s.mu.Lock()
+	ok := c.delete(s, key)
+	c.unlock(s)
+	return ok
+}
+
+// MDelete remove all keys from cache and return how many live entries have been removed,
+// every shard will be locked only once.
+func (c *LocalCache) MDelete(keys []Key) int {
+	n := 0
+	for i, group := range c.partition(keys) {
+		if len(group) == 0 {
+			continue
+		}
+		s := c.shards[i]
+		s.mu.Lock()
+		for _, key := range group {
+			if c.delete(s, key) {
+				n++
+			}
+		}
+		c.unlock(s)
+	}
+	return n
+}
+
+// DeleteFunc remove every live entry for which pred returns true and return how many have been removed.
+// pred is called while holding the lock, so it must not call back into the cache. A panic of pred is passed
+// to the caller after the lock released, entries removed before it stay removed.
+func (c *LocalCache) DeleteFunc(pred func(key Key, value interface{}) bool) int {
+	n := 0
+	for _, s := range c.shards {
+		n += c.deleteFunc(s, pred)
+	}
+	return n
+}
+
+// deleteFunc will do same as DeleteFunc for s, the lock is released by defer so a panic of pred
+// goes on to the caller without leaving s locked.
+func (c *LocalCache) deleteFunc(s *shard, pred func(key Key, value interface{}) bool) int {
+	n := 0
+	s.mu.Lock()
+	defer c.unlock(s)
+	for key, e := range s.data {
+		if !e.IsExpired() && pred(key, e.value) {
+			c.delete(s, key)
+			n++
+		}
+	}
+	return n
+}
+
+// KeyString return the string form of key rendered by KeyStringer, it can be used to log keys
+// in callbacks consistently.
+func (c *LocalCache) KeyString(key Key) string {
+	if c.config.KeyStringer != nil {
+		return c.config.KeyStringer(key)
+	}
+	return fmt.Sprint(key)
+}
+
+// DeletePrefix remove every live entry whose string key has prefix and return how many have been removed.
+// Keys which are not string are ignored, unless KeyStringer configured, then every key is rendered by it.
+func (c *LocalCache) DeletePrefix(prefix string) int {
+	return c.DeleteFunc(func(key Key, value interface{}) bool {
+		if c.config.KeyStringer != nil {
+			return strings.HasPrefix(c.config.KeyStringer(key), prefix)
+		}
+		k, ok := key.(string)
+		return ok && strings.HasPrefix(k, prefix)
+	})
+}
+
+// Items return a copy of all live key-value pairs except negative cached ones, which can be iterated
+// without holding any lock. It allocates a map as large as the cache, and every shard is copied
+// under its own lock, so the result is not a consistent snapshot across shards.
+// It will not affect stats and LRU recency.
+func (c *LocalCache) Items() map[Key]interface{} {
+	items := make(map[Key]interface{})
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for key, e := range s.data {
+			if !e.IsExpired() && !e.isNegative() {
+				items[key] = e.value
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return items
+}
+
+// ExpiringBefore return keys of live entries which will expire before t, ordered by expire time.
+// Entries never expire are skipped. It will not affect stats and LRU recency.
+func (c *LocalCache) ExpiringBefore(t time.Time) []Key {
+	var items []expiryItem
+	deadline := t.UnixNano()
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for _, item := range s.expiry {
+			if item.expire < deadline {
+				if e := s.data[item.key]; !e.IsExpired() {
+					items = append(items, *item)
+				}
+			}
+		}
+		s.mu.RUnlock()
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].expire < items[j].expire })
+	keys := make([]Key, len(items))
+	for i, item := range items {
+		keys[i] = item.key
+	}
+	return keys
+}
+
+// ExpiringEntry is the key and expire time of an entry returned by OldestEntries.
+type ExpiringEntry struct {
+	Key      Key
+	ExpireAt time.Time
+}
+
+// OldestEntries return at most n live entries which will expire soonest, ordered by expire time.
+// Entries never expire are excluded. It takes the read lock of shards only, so it will not affect
+// stats and LRU recency.
+func (c *LocalCache) OldestEntries(n int) []ExpiringEntry {
+	if n <= 0 {
+		return nil
+	}
+	var items []expiryItem
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for _, item := range s.expiry {
+			if e := s.data[item.key]; !e.IsExpired() {
+				items = append(items, *item)
+			}
+		}
+		s.mu.RUnlock()
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].expire < items[j].expire })
+	if len(items) > n {
+		items = items[:n]
+	}
+	entries := make([]ExpiringEntry, len(items))
+	for i, item := range items {
+		entries[i] = ExpiringEntry{Key: item.key, ExpireAt: time.Unix(0, item.expire)}
+	}
+	return entries
+}
+
 // Flush will reset all data in cache, but stats will be keeped.
+// Flushed entries are counted as Flushed in stats, and the evicted func is called for each of them.
 func (c *LocalCache) Flush() {
-	c.mu.Lock()
-	if c.evicted != nil {
-		for k, e := range c.data {
-			c.evicted(k, e)
-		}
+	c.flush(false)
+}
+
+// FlushSilent will do same as Flush but not call the evicted func, which is faster for a large cache.
+func (c *LocalCache) FlushSilent() {
+	c.flush(true)
+}
+
+func (c *LocalCache) flush(silent bool) {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.stats.Flushed += int64(len(s.data))
+		c.clear(s, silent)
+		c.unlock(s)
 	}
-	c.data = make(map[Key]Entry)
-	c.stats.Expired += c.stats.Entries
-	c.stats.Entries = 0
-	c.mu.Unlock()
 }
 
 // Reset will reset both data and stats.
 func (c *LocalCache) Reset() {
-	c.mu.Lock()
-	if c.evicted != nil {
-		for k, e := range c.data {
-			c.evicted(k, e)
+	c.reset(false)
+}
+
+// ResetSilent will do same as Reset but not call the evicted func, which is faster for a large cache.
+func (c *LocalCache) ResetSilent() {
+	c.reset(true)
+}
+
+func (c *LocalCache) reset(silent bool) {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		c.clear(s, silent)
+		s.stats = CacheStat{}
+		c.unlock(s)
+	}
+}
+
+// ResetStats will reset stats but keep data.
+func (c *LocalCache) ResetStats() {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.stats = CacheStat{}
+		s.mu.Unlock()
+	}
+}
+
+// clear remove all entries of s and evict them unless silent, the caller must hold the write lock of s.
+func (c *LocalCache) clear(s *shard, silent bool) {
+	evict := !silent && c.hasEvictFunc()
+	if evict || c.hub.listened() {
+		for k, e := range s.data {
+			if evict {
+				c.evict(s, k, e, EvictDeleted)
+			}
+			c.emit(s, k, OpDelete, e)
 		}
 	}
-	c.data = make(map[Key]Entry)
-	c.stats = &CacheStat{}
-	c.mu.Unlock()
+	s.reset()
+}
+
+// DebugString return a one-line summary of the live entries, main config and stats of cache for logging.
+// It scans all entries to count the live ones.
+func (c *LocalCache) DebugString() string {
+	b := make([]byte, 0, 256)
+	b = append(b, "live="...)
+	b = strconv.AppendInt(b, int64(c.LiveEntries()), 10)
+	b = append(b, " shards="...)
+	b = strconv.AppendInt(b, int64(len(c.shards)), 10)
+	b = append(b, " maxEntries="...)
+	b = strconv.AppendInt(b, int64(c.config.MaxEntries), 10)
+	b = append(b, " policy="...)
+	b = append(b, c.config.EvictionPolicy.String()...)
+	b = append(b, " expiration="...)
+	b = append(b, c.defaultExpiration().String()...)
+	b = append(b, " expireTick="...)
+	b = append(b, c.config.ExpireTick.String()...)
+	b = append(b, ' ')
+	return string(c.Stats().appendTo(b))
+}
+
+// LiveEntries return the number of entries not expired, it scans all entries under the read lock.
+func (c *LocalCache) LiveEntries() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for _, e := range s.data {
+			if !e.IsExpired() {
+				n++
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return n
 }
 
-// Stats return cache stats.
+// Stats return a snapshot of cache stats aggregated from all shards.
 func (c *LocalCache) Stats() *CacheStat {
-	c.mu.Lock()
-	stats := c.stats
-	c.mu.Unlock()
+	stats := &CacheStat{}
+	for _, s := range c.shards {
+		s.mu.RLock()
+		stats.Entries += int64(len(s.data))
+		stats.Bytes += atomic.LoadInt64(&s.bytes)
+		stats.Expired += atomic.LoadInt64(&s.stats.Expired)
+		stats.Flushed += atomic.LoadInt64(&s.stats.Flushed)
+		stats.Hits += atomic.LoadInt64(&s.stats.Hits)
+		stats.Misses += atomic.LoadInt64(&s.stats.Misses)
+		stats.Total += atomic.LoadInt64(&s.stats.Total)
+		stats.GetCount += atomic.LoadInt64(&s.stats.GetCount)
+		stats.GetNanos += atomic.LoadInt64(&s.stats.GetNanos)
+		stats.SetCount += atomic.LoadInt64(&s.stats.SetCount)
+		stats.SetNanos += atomic.LoadInt64(&s.stats.SetNanos)
+		s.mu.RUnlock()
+	}
 	return stats
 }
@@ -1,8 +1,18 @@
 package localcache_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -51,7 +61,8 @@ func TestGet(t *testing.T) {
 }
 
 func TestGetExpiration(t *testing.T) {
-	var localCache = localcache.NewLocalCache(nil)
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithClock(clock))
 	defer localCache.Flush()
 	localCache.Set("long", 123)
 	localCache.SetWithExpire("short", 1, time.Second)
@@ -73,7 +84,7 @@ shortValid:
 		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 1, v)
 	}
 longInvalid:
-	time.Sleep(time.Second)
+	clock.Advance(time.Second + time.Millisecond)
 	v, err = localCache.Get("long")
 	if err != nil {
 		t.Error(err)
@@ -110,7 +121,8 @@ func TestExpire(t *testing.T) {
 }
 
 func TestTimeoutExpiration(t *testing.T) {
-	var localCache = localcache.NewLocalCache(&localcache.CacheConfig{Expiration: time.Second})
+	clock := newFakeClock()
+	var localCache = localcache.NewLocalCache(&localcache.CacheConfig{Expiration: time.Second, Clock: clock})
 	localCache.Set("xxx", 1234)
 	v, err := localCache.GetInt64("xxx")
 	if err != nil {
@@ -120,7 +132,7 @@ func TestTimeoutExpiration(t *testing.T) {
 	if v != 1234 {
 		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 1234, v)
 	}
-	time.Sleep(time.Second)
+	clock.Advance(time.Second + time.Millisecond)
 	_, err = localCache.GetInt64("xxx")
 	if err != localcache.ErrExpiredKey {
 		t.Error(err)
@@ -161,6 +173,22 @@ func TestLocalCache_GetByte(t *testing.T) {
 	if v != 'b' {
 		t.Errorf("err: not equal, expect: %+v, but got %+v\n", 'b', v)
 	}
+	localCache.Set("int8", int8(-1))
+	localCache.Set("int", 200)
+	localCache.Set("uint", uint(255))
+	localCache.Set("negative", -1)
+	localCache.Set("large", 256)
+	localCache.Set("string", "b")
+	for key, expect := range map[string]byte{"int8": 255, "int": 200, "uint": 255} {
+		if v, err := localCache.GetByte(key); err != nil || v != expect {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", expect, v, err)
+		}
+	}
+	for _, key := range []string{"negative", "large", "string"} {
+		if _, err := localCache.GetByte(key); err != localcache.ErrTypeMismatch {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrTypeMismatch, err)
+		}
+	}
 }
 
 func TestLocalCache_GetUint64(t *testing.T) {
@@ -210,7 +238,8 @@ func TestLocalCache_Add(t *testing.T) {
 }
 
 func TestLocalCache_AddWithExpire(t *testing.T) {
-	var localCache = localcache.NewLocalCache(nil)
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithClock(clock))
 	localCache.AddWithExpire("123", 456, time.Second)
 	v, err := localCache.GetInt64("123")
 	if err != nil {
@@ -220,7 +249,7 @@ func TestLocalCache_AddWithExpire(t *testing.T) {
 	if v != 456 {
 		t.Errorf("err: not euqal, expect: %+v, but got: %+v\n", 456, v)
 	}
-	time.Sleep(time.Second)
+	clock.Advance(time.Second + time.Millisecond)
 	v, err = localCache.GetInt64("123")
 	if err != localcache.ErrExpiredKey {
 		t.Error(err)
@@ -273,5 +302,2191 @@ func TestLocalCache_GetEntry(t *testing.T) {
 }
 
 func TestLocalCache_GetKeysEntry(t *testing.T) {
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithClock(clock), localcache.WithShards(2))
+	defer localCache.Close()
+	localCache.Set("a", 1)
+	localCache.Set("b", 2)
+	localCache.SetWithExpire("expired", 3, time.Second)
+	clock.Advance(2 * time.Second)
+	v := localCache.GetKeysEntry([]localcache.Key{"a", "b", "expired", "missing"})
+	expect := map[localcache.Key]*localcache.ResponseEntry{
+		"a":       {Valid: true, Value: 1},
+		"b":       {Valid: true, Value: 2},
+		"expired": {Valid: false},
+		"missing": {Valid: false},
+	}
+	if !reflect.DeepEqual(v, expect) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect, v)
+	}
+	if stats := localCache.Stats(); stats.Hits != 2 || stats.Misses != 2 || stats.Expired != 1 || stats.Entries != 2 {
+		t.Errorf("err: unexpected stats: %+v\n", stats)
+	}
+}
+
+func TestLocalCache_Increment(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := localCache.Increment("counter", 1); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	v, err := localCache.GetInt64("counter")
+	if err != nil {
+		t.Error(err)
+	}
+	if v != 10000 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 10000, v)
+	}
+	n, err := localCache.Decrement("counter", 9999)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 1 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 1, n)
+	}
+}
+
+func TestLocalCache_IncrementTypeMismatch(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.Set("xxx", "abc")
+	_, err := localCache.Increment("xxx", 1)
+	if err != localcache.ErrTypeMismatch {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrTypeMismatch, err)
+	}
+	v, err := localCache.GetString("xxx")
+	if err != nil {
+		t.Error(err)
+	}
+	if v != "abc" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "abc", v)
+	}
+}
+
+func TestLocalCache_Touch(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.SetWithExpire("xxx", 1, 100*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if err := localCache.Touch("xxx", time.Second); err != nil {
+		t.Error(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := localCache.Get("xxx"); err != nil {
+		t.Error(err)
+	}
+	if err := localCache.Touch("yyy", time.Second); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+	localCache.SetWithExpire("zzz", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if err := localCache.Touch("zzz", time.Second); err != localcache.ErrExpiredKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrExpiredKey, err)
+	}
+}
+
+func TestLocalCache_GetAndDelete(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	for i := 0; i < 1000; i++ {
+		localCache.Set(i, i)
+	}
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		popped = make(map[interface{}]int)
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				v, err := localCache.GetAndDelete(j)
+				if err == localcache.ErrNoSuchKey {
+					continue
+				}
+				if err != nil {
+					t.Error(err)
+					continue
+				}
+				mu.Lock()
+				popped[v]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(popped) != 1000 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 1000, len(popped))
+	}
+	for v, n := range popped {
+		if n != 1 {
+			t.Errorf("err: value %+v popped %+v times\n", v, n)
+		}
+	}
+	if _, err := localCache.Get(0); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+}
+
+func TestLocalCache_Replace(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	if err := localCache.Replace("xxx", 1); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+	if _, err := localCache.Get("xxx"); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+	localCache.SetWithExpire("xxx", 1, time.Second)
+	if err := localCache.Replace("xxx", 2); err != nil {
+		t.Error(err)
+	}
+	v, ttl, err := localCache.GetWithExpire("xxx")
+	if err != nil {
+		t.Error(err)
+	}
+	if v != 2 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 2, v)
+	}
+	if ttl > time.Second {
+		t.Errorf("err: expiration not kept, got: %+v\n", ttl)
+	}
+	if err := localCache.ReplaceWithExpire("xxx", 3, time.Minute); err != nil {
+		t.Error(err)
+	}
+	_, ttl, _ = localCache.GetWithExpire("xxx")
+	if ttl <= time.Second {
+		t.Errorf("err: expiration not reset, got: %+v\n", ttl)
+	}
+	localCache.SetWithExpire("yyy", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if err := localCache.Replace("yyy", 2); err != localcache.ErrExpiredKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrExpiredKey, err)
+	}
+}
+
+func TestLocalCache_Has(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.Set("xxx", 1)
+	localCache.SetWithExpire("yyy", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if !localCache.Has("xxx") {
+		t.Errorf("err: expect key %+v exists\n", "xxx")
+	}
+	if localCache.Has("yyy") {
+		t.Errorf("err: expect key %+v expired\n", "yyy")
+	}
+	if localCache.Has("zzz") {
+		t.Errorf("err: expect key %+v not exists\n", "zzz")
+	}
+	stats := localCache.Stats()
+	if stats.Hits != 0 || stats.Misses != 0 || stats.Expired != 0 {
+		t.Errorf("err: stats changed, got: %+v\n", stats)
+	}
+}
+
+func TestLocalCache_TTL(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.SetWithExpire("xxx", 1, time.Minute)
+	localCache.SetWithExpire("forever", 1, 0)
+	localCache.SetWithExpire("yyy", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	ttl, err := localCache.TTL("xxx")
+	if err != nil {
+		t.Error(err)
+	}
+	if ttl <= 0 || ttl > time.Minute {
+		t.Errorf("err: ttl out of range, got: %+v\n", ttl)
+	}
+	ttl, err = localCache.TTL("forever")
+	if err != nil {
+		t.Error(err)
+	}
+	if ttl != localcache.NeverExpireDuration {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.NeverExpireDuration, ttl)
+	}
+	if _, err = localCache.TTL("yyy"); err != localcache.ErrExpiredKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrExpiredKey, err)
+	}
+	if _, err = localCache.TTL("zzz"); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+	if stats := localCache.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("err: stats changed, got: %+v\n", stats)
+	}
+}
+
+func TestLocalCache_Shards(t *testing.T) {
+	var localCache = localcache.NewLocalCache(&localcache.CacheConfig{Expiration: time.Minute, Shards: 10})
+	type key struct {
+		a int
+		b string
+	}
+	for i := 0; i < 1000; i++ {
+		localCache.Set(i, i)
+		localCache.Set(key{i, "x"}, i)
+	}
+	for i := 0; i < 1000; i++ {
+		v, err := localCache.GetInt64(i)
+		if err != nil {
+			t.Error(err)
+		}
+		if v != int64(i) {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", i, v)
+		}
+		if _, err = localCache.Get(key{i, "x"}); err != nil {
+			t.Error(err)
+		}
+	}
+	stats := localCache.Stats()
+	if stats.Entries != 2000 || stats.Hits != 2000 {
+		t.Errorf("err: not equal, entries expect %+v, but got %+v, hits expect %+v, but got %+v\n", 2000, stats.Entries, 2000, stats.Hits)
+	}
+	entries := localCache.GetKeysEntry([]localcache.Key{1, 2, 3, -1})
+	if !entries[1].Valid || !entries[2].Valid || !entries[3].Valid || entries[-1].Valid {
+		t.Errorf("err: unexpected entries: %+v\n", entries)
+	}
+}
+
+func TestLocalCache_ShardsPointerKey(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(16))
+	defer localCache.Close()
+	type item struct{ N int }
+	keys := make([]*item, 20)
+	for i := range keys {
+		keys[i] = &item{N: i}
+		localCache.Set(keys[i], i)
+	}
+	for i, key := range keys {
+		key.N = i + 100
+		if v, err := localCache.Get(key); err != nil || v != i {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v %+v\n", i, v, err)
+		}
+	}
+	negZero := math.Copysign(0, -1)
+	localCache.Set(0.0, "zero")
+	if v, err := localCache.Get(negZero); err != nil || v != "zero" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v %+v\n", "zero", v, err)
+	}
+}
+
+func TestLocalCache_GetStringKeyed(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(4))
+	defer localCache.Close()
+	localCache.Set("xxx", 1)
+	localCache.Set(1024, 2)
+	localCache.SetNegative("negative", time.Minute)
+	localCache.SetWithExpire("expired", 3, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if v, err := localCache.GetStringKeyed("xxx"); err != nil || v != 1 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v %+v\n", 1, v, err)
+	}
+	if v, err := localCache.GetIntKeyed(1024); err != nil || v != 2 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v %+v\n", 2, v, err)
+	}
+	if _, err := localCache.GetStringKeyed("missing"); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+	if _, err := localCache.GetStringKeyed("negative"); err != localcache.ErrNegativeCached {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNegativeCached, err)
+	}
+	if _, err := localCache.GetStringKeyed("expired"); err != localcache.ErrExpiredKey || localCache.Stats().Expired != 1 {
+		t.Errorf("err: expect expired key removed, but got: %+v\n", err)
+	}
+	if stats := localCache.Stats(); stats.Hits != 3 || stats.Misses != 2 {
+		t.Errorf("err: expect 3 hits and 2 misses, but got: %+v\n", stats)
+	}
+	var hits []localcache.Key
+	localCache.OnHit(func(key localcache.Key) { hits = append(hits, key) })
+	localCache.GetStringKeyed("xxx")
+	if !reflect.DeepEqual(hits, []localcache.Key{"xxx"}) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", []localcache.Key{"xxx"}, hits)
+	}
+}
+
+func TestLocalCache_ExpireSweep(t *testing.T) {
+	var localCache = localcache.NewLocalCache(&localcache.CacheConfig{ExpireTick: 10 * time.Millisecond})
+	localCache.SetWithExpire("short", 1, 20*time.Millisecond)
+	localCache.SetWithExpire("touched", 1, 20*time.Millisecond)
+	localCache.SetWithExpire("reset", 1, 20*time.Millisecond)
+	localCache.SetWithExpire("deleted", 1, 20*time.Millisecond)
+	localCache.SetWithExpire("forever", 1, 0)
+	localCache.Touch("touched", time.Minute)
+	localCache.SetWithExpire("reset", 2, 0)
+	localCache.Expire("deleted")
+	time.Sleep(100 * time.Millisecond)
+	stats := localCache.Stats()
+	if stats.Expired != 2 {
+		t.Errorf("err: not equal, expired expect %+v, but got %+v\n", 2, stats.Expired)
+	}
+	for _, key := range []string{"touched", "reset", "forever"} {
+		if !localCache.Has(key) {
+			t.Errorf("err: expect key %+v exists\n", key)
+		}
+	}
+}
+
+func TestLocalCache_OnEvict(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	var (
+		reasons = make(map[localcache.Key][]localcache.EvictReason)
+		legacy  = make(map[localcache.Key]bool)
+	)
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		reasons[key] = append(reasons[key], reason)
+	})
+	localCache.SetEvictedFunc(func(key localcache.Key, entry localcache.Entry) {
+		legacy[key] = true
+	})
+	localCache.SetWithExpire("expired", 1, 10*time.Millisecond)
+	localCache.Set("expire", 1)
+	localCache.Set("replaced", 1)
+	localCache.Set("deleted", 1)
+	time.Sleep(20 * time.Millisecond)
+	localCache.Get("expired")
+	localCache.Expire("expire")
+	localCache.Set("replaced", 2)
+	localCache.Flush()
+	for key, reason := range map[localcache.Key]localcache.EvictReason{
+		"expired":  localcache.EvictExpired,
+		"expire":   localcache.EvictExpired,
+		"replaced": localcache.EvictReplaced,
+		"deleted":  localcache.EvictDeleted,
+	} {
+		if r := reasons[key]; len(r) == 0 || r[0] != reason {
+			t.Errorf("err: not equal, key: %+v, expect: %+v, but got: %+v\n", key, reason, r)
+		}
+	}
+	if !legacy["expired"] || !legacy["deleted"] {
+		t.Errorf("err: evicted func not called, got: %+v\n", legacy)
+	}
+}
+
+func TestLocalCache_TrySetEvictedFunc(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	var first, second int
+	if err := localCache.TrySetEvictedFunc(func(localcache.Key, localcache.Entry) { first++ }); err != nil {
+		t.Error(err)
+	}
+	if err := localCache.TrySetEvictedFunc(evictedFunc); err != localcache.ErrDuplicateEvictedFunc {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrDuplicateEvictedFunc, err)
+	}
+	localCache.Set("xxx", 1)
+	localCache.Expire("xxx")
+	localCache.ReplaceEvictedFunc(func(localcache.Key, localcache.Entry) { second++ })
+	localCache.Set("xxx", 1)
+	localCache.Expire("xxx")
+	if first != 1 || second != 1 {
+		t.Errorf("err: not equal, expect: %+v and %+v calls, but got: %+v and %+v\n", 1, 1, first, second)
+	}
+}
+
+func TestLocalCache_GetBytes(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	bytes := []byte("abc")
+	localCache.Set("bytes", bytes)
+	localCache.Set("string", "def")
+	localCache.Set("int", 1)
+	v, err := localCache.GetBytes("bytes")
+	if err != nil {
+		t.Error(err)
+	}
+	if string(v) != "abc" || &v[0] != &bytes[0] {
+		t.Errorf("err: expect the stored slice, but got: %+v\n", v)
+	}
+	v, err = localCache.GetBytes("string")
+	if err != nil {
+		t.Error(err)
+	}
+	if string(v) != "def" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "def", string(v))
+	}
+	if _, err = localCache.GetBytes("int"); err != localcache.ErrTypeMismatch {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrTypeMismatch, err)
+	}
+}
+
+type getAsStruct struct {
+	Name string
+	Age  int
+}
+
+func TestGetAs(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.Set("slice", []string{"a", "b"})
+	localCache.Set("struct", getAsStruct{"x", 1})
+	s, err := localcache.GetAs[[]string](localCache, "slice")
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(s, []string{"a", "b"}) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", []string{"a", "b"}, s)
+	}
+	v, err := localcache.GetAs[getAsStruct](localCache, "struct")
+	if err != nil {
+		t.Error(err)
+	}
+	if v != (getAsStruct{"x", 1}) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", getAsStruct{"x", 1}, v)
+	}
+	p, err := localcache.GetAs[*getAsStruct](localCache, "struct")
+	if err != localcache.ErrTypeMismatch || p != nil {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrTypeMismatch, err)
+	}
+	if _, err = localcache.GetAs[int](localCache, "missing"); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+}
+
+func TestLocalCache_MSet(t *testing.T) {
+	var localCache = localcache.NewLocalCache(&localcache.CacheConfig{Shards: 4})
+	items := make(map[localcache.Key]interface{})
+	for i := 0; i < 100; i++ {
+		items[i] = i
+	}
+	localCache.MSetWithExpire(items, time.Minute)
+	for i := 0; i < 100; i++ {
+		v, err := localCache.Get(i)
+		if err != nil {
+			t.Error(err)
+		}
+		if v != i {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", i, v)
+		}
+	}
+	if ttl, _ := localCache.TTL(0); ttl > time.Minute {
+		t.Errorf("err: ttl out of range, got: %+v\n", ttl)
+	}
+}
+
+func TestLocalCache_MDelete(t *testing.T) {
+	var localCache = localcache.NewLocalCache(&localcache.CacheConfig{Shards: 4})
+	var deleted int
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		if reason == localcache.EvictDeleted {
+			deleted++
+		}
+	})
+	localCache.MSet(map[localcache.Key]interface{}{1: 1, 2: 2, 3: 3})
+	if n := localCache.MDelete([]localcache.Key{1, 2, 4}); n != 2 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 2, n)
+	}
+	if !localCache.Delete(3) || localCache.Delete(3) {
+		t.Errorf("err: expect key %+v deleted once\n", 3)
+	}
+	if deleted != 3 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 3, deleted)
+	}
+	for _, key := range []localcache.Key{1, 2, 3} {
+		if localCache.Has(key) {
+			t.Errorf("err: expect key %+v deleted\n", key)
+		}
+	}
+}
+
+func TestLocalCache_ExpireJitter(t *testing.T) {
+	var localCache = localcache.NewLocalCache(&localcache.CacheConfig{ExpireJitter: 10 * time.Second})
+	var min, max time.Duration = localcache.NeverExpireDuration, 0
+	for i := 0; i < 100; i++ {
+		localCache.SetWithExpire(i, i, time.Minute)
+		_, ttl, err := localCache.GetWithExpire(i)
+		if err != nil {
+			t.Error(err)
+		}
+		if ttl < 50*time.Second || ttl > 70*time.Second {
+			t.Errorf("err: ttl out of jitter window, got: %+v\n", ttl)
+		}
+		if ttl < min {
+			min = ttl
+		}
+		if ttl > max {
+			max = ttl
+		}
+	}
+	if max-min < 5*time.Second {
+		t.Errorf("err: ttl not spread, min: %+v, max: %+v\n", min, max)
+	}
+}
+
+func TestCacheStat_HitRatio(t *testing.T) {
+	for _, c := range []struct {
+		stats     localcache.CacheStat
+		hitRatio  float64
+		missRatio float64
+	}{
+		{localcache.CacheStat{}, 0, 0},
+		{localcache.CacheStat{Hits: 3, Misses: 1}, 0.75, 0.25},
+		{localcache.CacheStat{Hits: 5}, 1, 0},
+		{localcache.CacheStat{Misses: 5}, 0, 1},
+	} {
+		if r := c.stats.HitRatio(); r != c.hitRatio {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", c.hitRatio, r)
+		}
+		if r := c.stats.MissRatio(); r != c.missRatio {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", c.missRatio, r)
+		}
+	}
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.Set("xxx", 1)
+	localCache.GetKeysEntry([]localcache.Key{"xxx", "yyy"})
+	localCache.Get("xxx")
+	localCache.GetInt64("zzz")
+	stats := localCache.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("err: not equal, hits expect %+v, but got %+v, misses expect %+v, but got %+v\n", 2, stats.Hits, 2, stats.Misses)
+	}
+	if stats.HitRatio() != 0.5 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 0.5, stats.HitRatio())
+	}
+}
+
+func TestLocalCache_AsyncEvict(t *testing.T) {
+	var localCache = localcache.NewLocalCache(&localcache.CacheConfig{Expiration: time.Minute, AsyncEvict: true})
+	var calls int32
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		time.Sleep(200 * time.Millisecond)
+		atomic.AddInt32(&calls, 1)
+	})
+	localCache.Set("slow", 1)
+	localCache.Set("fast", 1)
+	start := time.Now()
+	localCache.Expire("slow")
+	if _, err := localCache.Get("fast"); err != nil {
+		t.Error(err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("err: get blocked by slow callback for %+v\n", elapsed)
+	}
+	localCache.Close()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("err: not equal, expect: %+v calls, but got: %+v\n", 1, n)
+	}
+}
+
+func TestLocalCache_AsyncEvictReentrant(t *testing.T) {
+	var localCache = localcache.New(localcache.WithAsyncEvict(1))
+	var calls int32
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		atomic.AddInt32(&calls, 1)
+		if k := key.(int); k < 1000 {
+			localCache.Set(k+1000, value)
+			localCache.Delete(k + 1000)
+		}
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			localCache.Set(i, i)
+			localCache.Delete(i)
+		}
+		for atomic.LoadInt32(&calls) < 20 {
+			time.Sleep(time.Millisecond)
+		}
+		localCache.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("err: deadlock in re-entrant async evicted callback\n")
+	}
+	if n := atomic.LoadInt32(&calls); n != 20 {
+		t.Errorf("err: not equal, expect: %+v calls, but got: %+v\n", 20, n)
+	}
+}
+
+func TestLocalCache_ReentrantEvictedFunc(t *testing.T) {
+	var localCache = localcache.NewLocalCache(&localcache.CacheConfig{ExpireTick: 10 * time.Millisecond})
+	localCache.SetEvictedFunc(func(key localcache.Key, entry localcache.Entry) {
+		if k, ok := key.(string); ok && !strings.HasPrefix(k, "evicted:") {
+			localCache.Set("evicted:"+k, true)
+		}
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		localCache.SetWithExpire("lazy", 1, 10*time.Millisecond)
+		localCache.SetWithExpire("sweep", 1, 10*time.Millisecond)
+		localCache.Set("expire", 1)
+		time.Sleep(15 * time.Millisecond)
+		localCache.Get("lazy")
+		localCache.Expire("expire")
+		time.Sleep(30 * time.Millisecond)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("err: deadlock in evicted func")
+	}
+	for _, key := range []string{"evicted:lazy", "evicted:expire", "evicted:sweep"} {
+		if !localCache.Has(key) {
+			t.Errorf("err: expect key %+v exists\n", key)
+		}
+	}
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		localCache.Set("flush", 1)
+		localCache.Flush()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("err: deadlock in evicted func")
+	}
+	if !localCache.Has("evicted:flush") {
+		t.Errorf("err: expect key %+v exists\n", "evicted:flush")
+	}
+}
+
+func TestLocalCache_SetForever(t *testing.T) {
+	var localCache = localcache.NewLocalCache(&localcache.CacheConfig{Expiration: 10 * time.Millisecond, ExpireTick: 10 * time.Millisecond})
+	localCache.SetForever("set", 1)
+	if err := localCache.AddForever("add", 1); err != nil {
+		t.Error(err)
+	}
+	if err := localCache.AddForever("add", 2); err != localcache.ErrDuplicateKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrDuplicateKey, err)
+	}
+	localCache.Set("default", 1)
+	time.Sleep(50 * time.Millisecond)
+	for _, key := range []string{"set", "add"} {
+		ttl, err := localCache.TTL(key)
+		if err != nil {
+			t.Error(err)
+		}
+		if ttl != localcache.NeverExpireDuration {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.NeverExpireDuration, ttl)
+		}
+	}
+	if localCache.Has("default") {
+		t.Errorf("err: expect key %+v expired\n", "default")
+	}
+}
+
+func TestNewLocalCacheContext(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	var localCache = localcache.NewLocalCacheContext(ctx, &localcache.CacheConfig{ExpireTick: time.Millisecond, AsyncEvict: true})
+	localCache.Set("xxx", 1)
+	if n := runtime.NumGoroutine(); n <= before {
+		t.Errorf("err: expect background goroutines started, before: %+v, now: %+v\n", before, n)
+	}
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("err: goroutine leaked, before: %+v, now: %+v\n", before, n)
+	}
+}
+
+func TestLocalCache_ConcurrentHits(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.Set("xxx", 1)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				localCache.Get("xxx")
+				localCache.Get("yyy")
+			}
+		}()
+	}
+	wg.Wait()
+	stats := localCache.Stats()
+	if stats.Hits != 10000 || stats.Misses != 10000 {
+		t.Errorf("err: not equal, hits expect %+v, but got %+v, misses expect %+v, but got %+v\n", 10000, stats.Hits, 10000, stats.Misses)
+	}
+}
+
+func TestLocalCache_Peek(t *testing.T) {
+	var localCache = localcache.New(localcache.WithMaxEntries(2))
+	localCache.Set("a", 1)
+	localCache.Set("b", 2)
+	v, err := localCache.Peek("a")
+	if err != nil {
+		t.Error(err)
+	}
+	if v != 1 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 1, v)
+	}
+	localCache.Set("c", 3)
+	if localCache.Has("a") || !localCache.Has("b") {
+		t.Errorf("err: expect peeked key %+v evicted as least recently used\n", "a")
+	}
+	localCache.SetWithExpire("d", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, err = localCache.Peek("d"); err != localcache.ErrExpiredKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrExpiredKey, err)
+	}
+	if _, err = localCache.Peek("e"); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+	if stats := localCache.Stats(); stats.Hits != 0 || stats.Misses != 0 || stats.Expired != 0 {
+		t.Errorf("err: stats changed, got: %+v\n", stats)
+	}
+}
+
+func TestLocalCache_SetNX(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.Set("present", 1)
+	localCache.SetWithExpire("expired", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	for key, applied := range map[string]bool{"present": false, "absent": true, "expired": true} {
+		if ok := localCache.SetNX(key, 2, time.Minute); ok != applied {
+			t.Errorf("err: not equal, key: %+v, expect: %+v, but got: %+v\n", key, applied, ok)
+		}
+		if v, _ := localCache.Get(key); (v == 2) != applied {
+			t.Errorf("err: unexpected value of key %+v: %+v\n", key, v)
+		}
+	}
+}
 
+func TestLocalCache_SetXX(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.Set("present", 1)
+	localCache.SetWithExpire("expired", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	for key, applied := range map[string]bool{"present": true, "absent": false, "expired": false} {
+		if ok := localCache.SetXX(key, 2, time.Minute); ok != applied {
+			t.Errorf("err: not equal, key: %+v, expect: %+v, but got: %+v\n", key, applied, ok)
+		}
+		if v, _ := localCache.Get(key); (v == 2) != applied {
+			t.Errorf("err: unexpected value of key %+v: %+v\n", key, v)
+		}
+	}
+}
+
+func TestLocalCache_GetFloat32(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.Set("float32", float32(1.5))
+	localCache.Set("float64", 2.5)
+	localCache.Set("int", 1)
+	for key, value := range map[string]float32{"float32": 1.5, "float64": 2.5} {
+		v, err := localCache.GetFloat32(key)
+		if err != nil {
+			t.Error(err)
+		}
+		if v != value {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", value, v)
+		}
+	}
+	if v, err := localCache.GetFloat64("float32"); err != nil || v != 1.5 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", 1.5, v, err)
+	}
+	if _, err := localCache.GetFloat32("int"); err != localcache.ErrTypeMismatch {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrTypeMismatch, err)
+	}
+}
+
+func TestLocalCache_GetComplex128(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.Set("complex64", complex64(1+2i))
+	localCache.Set("complex128", 3+4i)
+	localCache.Set("float64", 1.0)
+	for key, value := range map[string]complex128{"complex64": 1 + 2i, "complex128": 3 + 4i} {
+		v, err := localCache.GetComplex128(key)
+		if err != nil {
+			t.Error(err)
+		}
+		if v != value {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", value, v)
+		}
+	}
+	if _, err := localCache.GetComplex128("float64"); err != localcache.ErrTypeMismatch {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrTypeMismatch, err)
+	}
+}
+
+func TestLocalCache_DeleteFuncPanic(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(4), localcache.WithKeyStringer(func(key localcache.Key) string {
+		panic("bad stringer")
+	}))
+	defer localCache.Close()
+	for i := 0; i < 8; i++ {
+		localCache.Set(i, i)
+	}
+	for _, remove := range []func(){
+		func() { localCache.DeleteFunc(func(key localcache.Key, value interface{}) bool { panic("bad pred") }) },
+		func() { localCache.DeletePrefix("1") },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("err: expect panic passed to caller\n")
+				}
+			}()
+			remove()
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 8; i++ {
+			localCache.Set(i, i+1)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("err: shard locked after panic of DeleteFunc\n")
+	}
+}
+
+func TestLocalCache_DeleteFunc(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(4))
+	var deleted int
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		if reason == localcache.EvictDeleted {
+			deleted++
+		}
+	})
+	for i := 0; i < 10; i++ {
+		localCache.Set(fmt.Sprintf("user:%d", i), i)
+		localCache.Set(fmt.Sprintf("item:%d", i), i)
+	}
+	localCache.Set(1, 1)
+	n := localCache.DeleteFunc(func(key localcache.Key, value interface{}) bool {
+		k, ok := key.(string)
+		return ok && strings.HasPrefix(k, "user:")
+	})
+	if n != 10 || deleted != 10 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v removed and %+v evicted\n", 10, n, deleted)
+	}
+	for i := 0; i < 10; i++ {
+		if localCache.Has(fmt.Sprintf("user:%d", i)) || !localCache.Has(fmt.Sprintf("item:%d", i)) {
+			t.Errorf("err: unexpected keys after DeleteFunc\n")
+		}
+	}
+	if !localCache.Has(1) {
+		t.Errorf("err: expect key %+v exists\n", 1)
+	}
+}
+
+func TestLocalCache_GetMulti(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(4))
+	localCache.Set("present", 1)
+	localCache.SetWithExpire("expired", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	found, missing := localCache.GetMulti([]localcache.Key{"present", "expired", "absent"})
+	if !reflect.DeepEqual(found, map[localcache.Key]interface{}{"present": 1}) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", map[localcache.Key]interface{}{"present": 1}, found)
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].(string) < missing[j].(string) })
+	if !reflect.DeepEqual(missing, []localcache.Key{"absent", "expired"}) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", []localcache.Key{"absent", "expired"}, missing)
+	}
+	if stats := localCache.Stats(); stats.Hits != 1 || stats.Misses != 2 || stats.Expired != 1 {
+		t.Errorf("err: unexpected stats: %+v\n", stats)
+	}
+}
+
+func TestLocalCache_SetNegative(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.SetNegative("xxx", 20*time.Millisecond)
+	if _, err := localCache.Get("xxx"); err != localcache.ErrNegativeCached {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNegativeCached, err)
+	}
+	if _, err := localCache.GetString("xxx"); err != localcache.ErrNegativeCached {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNegativeCached, err)
+	}
+	if entry, err := localCache.GetEntry("xxx"); err != localcache.ErrNegativeCached || entry.Valid {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNegativeCached, err)
+	}
+	if !localCache.Has("xxx") {
+		t.Errorf("err: expect negative key %+v exists\n", "xxx")
+	}
+	found, missing := localCache.GetMulti([]localcache.Key{"xxx"})
+	if len(found) != 0 || len(missing) != 0 {
+		t.Errorf("err: expect negative key neither found nor missing, got: %+v, %+v\n", found, missing)
+	}
+	if stats := localCache.Stats(); stats.Hits != 4 {
+		t.Errorf("err: not equal, hits expect %+v, but got %+v\n", 4, stats.Hits)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := localCache.Get("xxx"); err != localcache.ErrExpiredKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrExpiredKey, err)
+	}
+}
+
+func TestLocalCache_EntryInfo(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	before := time.Now()
+	localCache.SetWithExpire("xxx", 1, time.Minute)
+	localCache.SetForever("forever", 1)
+	created, accessed, expireAt, err := localCache.EntryInfo("xxx")
+	if err != nil {
+		t.Error(err)
+	}
+	if created.Before(before) || !accessed.Equal(created) {
+		t.Errorf("err: unexpected created: %+v, accessed: %+v\n", created, accessed)
+	}
+	if d := expireAt.Sub(created); d < time.Minute-time.Second || d > time.Minute {
+		t.Errorf("err: unexpected expireAt: %+v\n", expireAt)
+	}
+	time.Sleep(5 * time.Millisecond)
+	localCache.Get("xxx")
+	_, accessed2, _, _ := localCache.EntryInfo("xxx")
+	if !accessed2.After(accessed) {
+		t.Errorf("err: expect access time advanced, before: %+v, after: %+v\n", accessed, accessed2)
+	}
+	if _, _, expireAt, _ = localCache.EntryInfo("forever"); !expireAt.IsZero() {
+		t.Errorf("err: expect zero expireAt, but got: %+v\n", expireAt)
+	}
+	if _, _, _, err = localCache.EntryInfo("yyy"); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+}
+
+func TestLocalCache_GetOK(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.Set("nil", nil)
+	v, err := localCache.Get("nil")
+	if v != nil || err != nil {
+		t.Errorf("err: not equal, expect: %+v, %+v, but got: %+v, %+v\n", nil, nil, v, err)
+	}
+	if _, err = localCache.Get("missing"); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+	if v, ok := localCache.GetOK("nil"); !ok || v != nil {
+		t.Errorf("err: expect stored nil found, but got: %+v, %+v\n", v, ok)
+	}
+	if _, ok := localCache.GetOK("missing"); ok {
+		t.Errorf("err: expect key %+v not found\n", "missing")
+	}
+	if entry, _ := localCache.GetEntry("nil"); !entry.Valid || entry.Value != nil {
+		t.Errorf("err: expect valid nil entry, but got: %+v\n", entry)
+	}
+}
+
+func TestLocalCache_Sliding(t *testing.T) {
+	var localCache = localcache.New(localcache.WithSliding(), localcache.WithExpireTick(time.Millisecond*10))
+	defer localCache.Close()
+	localCache.SetWithExpire("sliding", 1, time.Millisecond*100)
+	localCache.SetWithExpire("fixed", 1, time.Millisecond*100)
+	for i := 0; i < 6; i++ {
+		time.Sleep(time.Millisecond * 50)
+		if _, err := localCache.Get("sliding"); err != nil {
+			t.Fatalf("err: expect sliding key alive after %v, but got: %+v\n", time.Duration(i+1)*time.Millisecond*50, err)
+		}
+	}
+	if localCache.Has("fixed") {
+		t.Errorf("err: expect not read key expired\n")
+	}
+	time.Sleep(time.Millisecond * 150)
+	if localCache.Has("sliding") {
+		t.Errorf("err: expect sliding key expired after idle\n")
+	}
+}
+
+func TestLocalCache_FlushSilent(t *testing.T) {
+	var evicted int64
+	var localCache = localcache.New(localcache.WithEvictedFunc(func(key localcache.Key, entry localcache.Entry) {
+		atomic.AddInt64(&evicted, 1)
+	}))
+	defer localCache.Close()
+	localCache.MSet(map[localcache.Key]interface{}{"1": 1, "2": 2})
+	localCache.FlushSilent()
+	if n := atomic.LoadInt64(&evicted); n != 0 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 0, n)
+	}
+	if stats := localCache.Stats(); stats.Entries != 0 || stats.Flushed != 2 || stats.Expired != 0 {
+		t.Errorf("err: unexpected stats after FlushSilent: %+v\n", stats)
+	}
+	localCache.MSet(map[localcache.Key]interface{}{"1": 1, "2": 2})
+	localCache.Flush()
+	if n := atomic.LoadInt64(&evicted); n != 2 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 2, n)
+	}
+	localCache.MSet(map[localcache.Key]interface{}{"1": 1, "2": 2})
+	localCache.ResetSilent()
+	if n := atomic.LoadInt64(&evicted); n != 2 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 2, n)
+	}
+	if stats := localCache.Stats(); *stats != (localcache.CacheStat{}) || localCache.Has("1") {
+		t.Errorf("err: unexpected stats after ResetSilent: %+v\n", stats)
+	}
+	localCache.MSet(map[localcache.Key]interface{}{"1": 1, "2": 2})
+	localCache.Reset()
+	if n := atomic.LoadInt64(&evicted); n != 4 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 4, n)
+	}
+}
+
+func TestLocalCache_Items(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	localCache.MSet(map[localcache.Key]interface{}{"1": 1, "2": 2})
+	localCache.SetWithExpire("expired", 3, time.Nanosecond)
+	localCache.SetNegative("negative", time.Minute)
+	time.Sleep(time.Millisecond)
+	items := localCache.Items()
+	expect := map[localcache.Key]interface{}{"1": 1, "2": 2}
+	if !reflect.DeepEqual(items, expect) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect, items)
+	}
+	localCache.Set("1", 10)
+	localCache.Delete("2")
+	localCache.Set("4", 4)
+	if !reflect.DeepEqual(items, expect) {
+		t.Errorf("err: expect items independent of cache, but got: %+v\n", items)
+	}
+}
+
+func TestLocalCache_MaxValueBytes(t *testing.T) {
+	sizer := func(value interface{}) int64 {
+		if s, ok := value.(string); ok {
+			return int64(len(s))
+		}
+		return 0
+	}
+	var localCache = localcache.New(localcache.WithMaxValueBytes(4, sizer))
+	defer localCache.Close()
+	if err := localCache.TrySet("small", "abcd"); err != nil {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", nil, err)
+	}
+	if err := localCache.TrySet("large", "abcde"); err != localcache.ErrValueTooLarge {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrValueTooLarge, err)
+	}
+	if err := localCache.Add("large", "abcde"); err != localcache.ErrValueTooLarge {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrValueTooLarge, err)
+	}
+	if err := localCache.Replace("small", "abcde"); err != localcache.ErrValueTooLarge {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrValueTooLarge, err)
+	}
+	if v, _ := localCache.Get("small"); v != "abcd" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "abcd", v)
+	}
+	if localCache.Has("large") {
+		t.Errorf("err: expect large value rejected\n")
+	}
+}
+
+func TestLocalCache_MaxValueBytesStale(t *testing.T) {
+	sizer := func(value interface{}) int64 {
+		return int64(len(value.(string)))
+	}
+	var localCache = localcache.New(localcache.WithMaxValueBytes(4, sizer))
+	defer localCache.Close()
+	var reasons = make(map[localcache.Key]localcache.EvictReason)
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		reasons[key] = reason
+	})
+	localCache.MSet(map[localcache.Key]interface{}{"a": "1", "b": "2", "c": "3", "d": "4"})
+	localCache.SetIfNewer("e", "5", 2, time.Minute)
+	localCache.Set("a", "abcde")
+	localCache.MSet(map[localcache.Key]interface{}{"b": "abcde", "c": "33"})
+	localCache.SetKeepTTL("d", "abcde")
+	localCache.SetIfNewer("e", "abcde", 1, time.Minute)
+	if v, _ := localCache.Get("e"); v != "5" {
+		t.Errorf("err: expect older version ignored, but got: %+v\n", v)
+	}
+	localCache.SetIfNewer("e", "abcde", 3, time.Minute)
+	for _, key := range []localcache.Key{"a", "b", "d", "e"} {
+		if _, err := localCache.Get(key); err != localcache.ErrNoSuchKey {
+			t.Errorf("err: expect stale value of %+v removed, but got: %+v\n", key, err)
+		}
+	}
+	if v, _ := localCache.Get("c"); v != "33" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "33", v)
+	}
+	expect := map[localcache.Key]localcache.EvictReason{
+		"a": localcache.EvictDeleted, "b": localcache.EvictDeleted, "c": localcache.EvictReplaced,
+		"d": localcache.EvictDeleted, "e": localcache.EvictDeleted,
+	}
+	if !reflect.DeepEqual(reasons, expect) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect, reasons)
+	}
+}
+
+func TestLocalCache_Rename(t *testing.T) {
+	var reasons = make(map[localcache.Key]localcache.EvictReason)
+	var mu sync.Mutex
+	var localCache = localcache.New(localcache.WithShards(4))
+	defer localCache.Close()
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		mu.Lock()
+		reasons[value] = reason
+		mu.Unlock()
+	})
+	localCache.SetWithExpire("old", "value", time.Minute)
+	if err := localCache.Rename("old", "new"); err != nil {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", nil, err)
+	}
+	if localCache.Has("old") {
+		t.Errorf("err: expect old key removed\n")
+	}
+	v, ttl, err := localCache.GetWithExpire("new")
+	if err != nil || v != "value" || ttl > time.Minute || ttl < time.Minute-time.Second {
+		t.Errorf("err: expect value and ttl moved, but got: %+v, %+v, %+v\n", v, ttl, err)
+	}
+	if err := localCache.Rename("missing", "new"); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+	localCache.Set("other", "other")
+	if err := localCache.Rename("other", "new"); err != nil {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", nil, err)
+	}
+	if v, _ := localCache.Get("new"); v != "other" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "other", v)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reasons) != 1 || reasons["value"] != localcache.EvictReplaced {
+		t.Errorf("err: expect only overwritten destination evicted, but got: %+v\n", reasons)
+	}
+}
+
+func TestLocalCache_RenameReentrant(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(8))
+	defer localCache.Close()
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		for i := 0; i < 64; i++ {
+			localCache.Has(i)
+		}
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 16; i++ {
+			localCache.Set(i, i)
+			localCache.Set(i+100, i)
+			localCache.Rename(i, i+100)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("err: deadlock in evicted callback of Rename\n")
+	}
+}
+
+func TestLocalCache_OnHitMiss(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(4))
+	defer localCache.Close()
+	var hits, misses []localcache.Key
+	localCache.OnHit(func(key localcache.Key) {
+		hits = append(hits, key)
+		localCache.Has(key)
+	})
+	localCache.OnMiss(func(key localcache.Key) {
+		misses = append(misses, key)
+	})
+	localCache.MSet(map[localcache.Key]interface{}{"1": 1, "2": 2})
+	localCache.SetNegative("negative", time.Minute)
+	localCache.Get("1")
+	localCache.Get("missing")
+	localCache.Get("negative")
+	localCache.GetInt64("2")
+	localCache.Peek("1")
+	localCache.GetKeysEntry([]localcache.Key{"1", "2", "3"})
+	localCache.GetAndDelete("1")
+	localCache.GetAndDelete("1")
+	if len(hits) != 6 || len(misses) != 3 {
+		t.Errorf("err: not equal, expect: %+v hits and %+v misses, but got: %+v, %+v\n", 6, 3, hits, misses)
+	}
+	if stats := localCache.Stats(); stats.Hits != int64(len(hits)) || stats.Misses != int64(len(misses)) {
+		t.Errorf("err: expect hooks consistent with stats, but got: %+v\n", stats)
+	}
+}
+
+func TestLocalCache_ExpireBudget(t *testing.T) {
+	const total = 100000
+	var localCache = localcache.New(localcache.WithExpireTick(time.Millisecond), localcache.WithExpireBudget(1000))
+	defer localCache.Close()
+	items := make(map[localcache.Key]interface{}, total)
+	for i := 0; i < total; i++ {
+		items[i] = i
+	}
+	localCache.MSetWithExpire(items, 20*time.Millisecond)
+	localCache.SetForever("live", 1)
+	var slowest time.Duration
+	deadline := time.Now().Add(5 * time.Second)
+	for localCache.Stats().Expired < total && time.Now().Before(deadline) {
+		start := time.Now()
+		if _, err := localCache.Get("live"); err != nil {
+			t.Fatal(err)
+		}
+		if d := time.Since(start); d > slowest {
+			slowest = d
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+	if n := localCache.Stats().Expired; n != total {
+		t.Errorf("err: not equal, expect: %+v expired, but got: %+v\n", total, n)
+	}
+	if slowest > 50*time.Millisecond {
+		t.Errorf("err: expect Get not blocked by sweep, but the slowest took: %+v\n", slowest)
+	}
+}
+
+func TestLocalCache_ResetStats(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(4))
+	defer localCache.Close()
+	localCache.MSet(map[localcache.Key]interface{}{"1": 1, "2": 2})
+	localCache.Set("1", 10)
+	localCache.Get("1")
+	localCache.Get("missing")
+	localCache.ResetStats()
+	expect := localcache.CacheStat{Entries: 2}
+	if stats := localCache.Stats(); *stats != expect {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect, *stats)
+	}
+	if v, err := localCache.Get("1"); err != nil || v != 10 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", 10, v, err)
+	}
+}
+
+func TestLocalCache_SetWithExpireAt(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	at := time.Now().Add(time.Hour)
+	localCache.SetWithExpireAt("future", 1, at)
+	if _, _, expireAt, err := localCache.EntryInfo("future"); err != nil || !expireAt.Equal(at) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", at, expireAt, err)
+	}
+	localCache.SetWithExpireAt("past", 1, time.Now().Add(-time.Second))
+	if _, err := localCache.Get("past"); err != localcache.ErrExpiredKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrExpiredKey, err)
+	}
+	localCache.SetWithExpireAt("forever", 1, time.Time{})
+	if ttl, _ := localCache.TTL("forever"); ttl != localcache.NeverExpireDuration {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.NeverExpireDuration, ttl)
+	}
+	if err := localCache.AddWithExpireAt("future", 2, at); err != localcache.ErrDuplicateKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrDuplicateKey, err)
+	}
+	if err := localCache.AddWithExpireAt("past", 2, time.Now().Add(time.Minute)); err != nil {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", nil, err)
+	}
+}
+
+func TestLocalCache_IdleTimeout(t *testing.T) {
+	var localCache = localcache.New(localcache.WithIdleTimeout(50*time.Millisecond), localcache.WithExpireTick(10*time.Millisecond))
+	defer localCache.Close()
+	localCache.SetWithExpire("active", 1, time.Minute)
+	localCache.SetWithExpire("idle", 1, time.Minute)
+	for i := 0; i < 8; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if _, err := localCache.Get("active"); err != nil {
+			t.Fatalf("err: expect active key alive, but got: %+v\n", err)
+		}
+	}
+	if _, err := localCache.Peek("idle"); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: expect idle key removed by sweep, but got: %+v\n", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if localCache.Has("active") {
+		t.Errorf("err: expect active key expired after idle\n")
+	}
+	if n := localCache.Stats().Expired; n != 2 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 2, n)
+	}
+}
+
+func TestLocalCache_ExpiringBefore(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(4))
+	defer localCache.Close()
+	localCache.SetWithExpire("3m", 1, 3*time.Minute)
+	localCache.SetWithExpire("1m", 1, time.Minute)
+	localCache.SetWithExpire("2m", 1, 2*time.Minute)
+	localCache.SetWithExpire("10m", 1, 10*time.Minute)
+	localCache.SetWithExpire("expired", 1, time.Nanosecond)
+	localCache.SetForever("forever", 1)
+	time.Sleep(time.Millisecond)
+	keys := localCache.ExpiringBefore(time.Now().Add(5 * time.Minute))
+	expect := []localcache.Key{"1m", "2m", "3m"}
+	if !reflect.DeepEqual(keys, expect) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect, keys)
+	}
+}
+
+func TestLocalCache_DeletePrefix(t *testing.T) {
+	var evicted []localcache.Key
+	var localCache = localcache.New(localcache.WithShards(4))
+	defer localCache.Close()
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		evicted = append(evicted, key)
+	})
+	localCache.MSet(map[localcache.Key]interface{}{
+		"user:123:name":             1,
+		"user:123:age":              2,
+		"user:456:name":             3,
+		123:                         4,
+		[2]string{"user:123:", "x"}: 5,
+	})
+	if n := localCache.DeletePrefix("user:123:"); n != 2 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 2, n)
+	}
+	if len(evicted) != 2 {
+		t.Errorf("err: not equal, expect: %+v evictions, but got: %+v\n", 2, evicted)
+	}
+	for _, key := range []localcache.Key{"user:456:name", 123, [2]string{"user:123:", "x"}} {
+		if !localCache.Has(key) {
+			t.Errorf("err: expect key %+v kept\n", key)
+		}
+	}
+	if n := localCache.DeletePrefix("1"); n != 0 || !localCache.Has(123) {
+		t.Errorf("err: expect int key not matched by default, but %+v removed\n", n)
+	}
+}
+
+func TestLocalCache_GetOrDefault(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	localCache.Set("string", "value")
+	localCache.Set("int", 1)
+	localCache.SetWithExpire("expired", "value", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if v := localCache.GetOrDefault("string", "def"); v != "value" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "value", v)
+	}
+	if v := localCache.GetOrDefault("missing", "def"); v != "def" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "def", v)
+	}
+	if v := localCache.GetStringOr("expired", "def"); v != "def" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "def", v)
+	}
+	if v := localCache.GetStringOr("int", "def"); v != "def" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "def", v)
+	}
+	if v := localCache.GetInt64Or("int", 2); v != 1 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 1, v)
+	}
+	if v := localCache.GetBoolOr("missing", true); !v {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", true, v)
+	}
+	if v := localCache.GetFloat64Or("string", 1.5); v != 1.5 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 1.5, v)
+	}
+}
+
+func TestLocalCache_GetEntryWithExpire(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	localCache.SetWithExpire("xxx", 1, time.Minute)
+	v, ttl, err := localCache.GetEntryWithExpire("xxx")
+	if err != nil || !v.Valid || v.Value != 1 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", 1, v, err)
+	}
+	if ttl > time.Minute || ttl < time.Minute-time.Second {
+		t.Errorf("err: ttl out of range, got: %+v\n", ttl)
+	}
+	v, ttl, err = localCache.GetEntryWithExpire("missing")
+	if err != localcache.ErrNoSuchKey || v.Valid || ttl != localcache.ExpireDuration {
+		t.Errorf("err: unexpected result of missing key: %+v, %+v, %+v\n", v, ttl, err)
+	}
+	if stats := localCache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("err: unexpected stats: %+v\n", stats)
+	}
+}
+
+func TestLocalCache_HugeDuration(t *testing.T) {
+	var localCache = localcache.New(localcache.WithExpireJitter(time.Minute))
+	defer localCache.Close()
+	localCache.SetWithExpire("max", 1, localcache.NeverExpireDuration)
+	localCache.SetWithExpire("huge", 1, localcache.NeverExpireDuration-time.Second)
+	localCache.SetWithExpireAt("far", 1, time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC))
+	for _, key := range []string{"max", "huge", "far"} {
+		if _, err := localCache.Get(key); err != nil {
+			t.Errorf("err: expect key %+v readable, but got: %+v\n", key, err)
+		}
+		if ttl, _ := localCache.TTL(key); ttl != localcache.NeverExpireDuration {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.NeverExpireDuration, ttl)
+		}
+	}
+}
+
+func TestLocalCache_TrackLatency(t *testing.T) {
+	for _, track := range []bool{false, true} {
+		var localCache = localcache.NewLocalCache(&localcache.CacheConfig{TrackLatency: track})
+		localCache.Set("1", 1)
+		localCache.Add("2", 2)
+		localCache.Get("1")
+		localCache.GetString("missing")
+		stats := localCache.Stats()
+		localCache.Close()
+		if !track {
+			if stats.GetCount != 0 || stats.GetNanos != 0 || stats.SetCount != 0 || stats.SetNanos != 0 {
+				t.Errorf("err: expect no latency recorded, but got: %+v\n", stats)
+			}
+			continue
+		}
+		if stats.GetCount != 2 || stats.SetCount != 2 || stats.GetNanos <= 0 || stats.SetNanos <= 0 {
+			t.Errorf("err: expect latency recorded, but got: %+v\n", stats)
+		}
+		if stats.AvgGetLatency() <= 0 || stats.AvgSetLatency() <= 0 {
+			t.Errorf("err: expect positive average latency, but got: %+v, %+v\n", stats.AvgGetLatency(), stats.AvgSetLatency())
+		}
+	}
+}
+
+func TestLocalCache_LiveEntries(t *testing.T) {
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithClock(clock), localcache.WithShards(4))
+	defer localCache.Close()
+	localCache.Set("1", 1)
+	localCache.Set("1", 10)
+	localCache.SetWithExpire("2", 2, time.Second)
+	localCache.SetWithExpire("3", 3, time.Second)
+	clock.Advance(2 * time.Second)
+	if stats := localCache.Stats(); stats.Entries != 3 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 3, stats.Entries)
+	}
+	if n := localCache.LiveEntries(); n != 1 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 1, n)
+	}
+	localCache.Get("2")
+	if stats := localCache.Stats(); stats.Entries != 2 || stats.Expired != 1 {
+		t.Errorf("err: unexpected stats after lazy expiration: %+v\n", stats)
+	}
+}
+
+func TestLocalCache_NoSweep(t *testing.T) {
+	clock := newFakeClock()
+	before := runtime.NumGoroutine()
+	var localCache = localcache.New(localcache.WithExpireTick(0), localcache.WithClock(clock))
+	if n := runtime.NumGoroutine(); n != before {
+		t.Errorf("err: expect no goroutine started, but got: %+v more\n", n-before)
+	}
+	localCache.SetWithExpire("xxx", 1, time.Second)
+	clock.Advance(2 * time.Second)
+	if stats := localCache.Stats(); stats.Entries != 1 {
+		t.Errorf("err: expect expired entry kept without sweep, but got: %+v\n", stats)
+	}
+	if _, err := localCache.Get("xxx"); err != localcache.ErrExpiredKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrExpiredKey, err)
+	}
+	if stats := localCache.Stats(); stats.Entries != 0 || stats.Expired != 1 {
+		t.Errorf("err: expect expired entry removed lazily, but got: %+v\n", stats)
+	}
+	localCache.Close()
+}
+
+func TestLocalCache_SetIfNewer(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	updates := []struct {
+		version int64
+		applied bool
+	}{{2, true}, {1, false}, {3, true}, {3, false}, {5, true}, {4, false}}
+	for _, u := range updates {
+		if ok := localCache.SetIfNewer("xxx", u.version, u.version, time.Minute); ok != u.applied {
+			t.Errorf("err: version %+v, expect applied: %+v, but got: %+v\n", u.version, u.applied, ok)
+		}
+	}
+	if v, _ := localCache.Get("xxx"); v != int64(5) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 5, v)
+	}
+	localCache.Set("xxx", 0)
+	if !localCache.SetIfNewer("xxx", 1, 1, time.Minute) {
+		t.Errorf("err: expect value stored by Set has version 0\n")
+	}
+}
+
+func TestLocalCache_OnClose(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	var calls int
+	var items map[localcache.Key]interface{}
+	localCache.OnClose(func(m map[localcache.Key]interface{}) {
+		calls++
+		items = m
+	})
+	localCache.MSet(map[localcache.Key]interface{}{"1": 1, "2": 2})
+	localCache.SetWithExpire("expired", 3, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	localCache.Close()
+	localCache.Close()
+	expect := map[localcache.Key]interface{}{"1": 1, "2": 2}
+	if calls != 1 || !reflect.DeepEqual(items, expect) {
+		t.Errorf("err: not equal, expect: %+v once, but got: %+v %+v times\n", expect, items, calls)
+	}
+}
+
+func TestLocalCache_Closed(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.Set("xxx", 1)
+	localCache.Close()
+	if _, err := localCache.Get("xxx"); err != localcache.ErrCacheClosed {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrCacheClosed, err)
+	}
+	localCache.Set("yyy", 2)
+	if err := localCache.TrySet("yyy", 2); err != localcache.ErrCacheClosed {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrCacheClosed, err)
+	}
+	if err := localCache.Add("zzz", 3); err != localcache.ErrCacheClosed {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrCacheClosed, err)
+	}
+	var buf bytes.Buffer
+	if err := localCache.Save(&buf); err != nil {
+		t.Error(err)
+	}
+	calls := map[string]func() error{
+		"WaitFor": func() error { _, err := localCache.WaitFor(context.Background(), "xxx"); return err },
+		"Peek":    func() error { _, err := localCache.Peek("xxx"); return err },
+		"GetStale": func() error {
+			_, _, err := localCache.GetStale("xxx")
+			return err
+		},
+		"GetAndDelete": func() error { _, err := localCache.GetAndDelete("xxx"); return err },
+		"TTL":          func() error { _, err := localCache.TTL("xxx"); return err },
+		"GetOrCompute": func() error {
+			_, err := localCache.GetOrCompute("xxx", time.Minute, func() (interface{}, error) { return 2, nil })
+			return err
+		},
+		"Replace":   func() error { return localCache.Replace("xxx", 2) },
+		"Increment": func() error { _, err := localCache.Increment("xxx", 1); return err },
+		"Update": func() error {
+			return localCache.Update("xxx", func(old interface{}, found bool) (interface{}, bool) { return 2, true })
+		},
+		"Touch":  func() error { return localCache.Touch("xxx", time.Minute) },
+		"Rename": func() error { return localCache.Rename("xxx", "yyy") },
+		"Load":   func() error { return localCache.Load(&buf) },
+	}
+	for name, call := range calls {
+		if err := call(); err != localcache.ErrCacheClosed {
+			t.Errorf("err: %s not equal, expect: %+v, but got: %+v\n", name, localcache.ErrCacheClosed, err)
+		}
+	}
+	if localCache.Has("xxx") || localCache.SetXX("xxx", 2, 0) || localCache.SetIfNewer("xxx", 2, 1, 0) {
+		t.Errorf("err: expect nothing found or stored after close\n")
+	}
+	if found, _ := localCache.GetMulti([]localcache.Key{"xxx"}); len(found) != 0 {
+		t.Errorf("err: expect nothing found after close, but got: %+v\n", found)
+	}
+	localCache.MSet(map[localcache.Key]interface{}{"yyy": 2})
+	localCache.SetKeepTTL("xxx", 2)
+	expect := map[localcache.Key]interface{}{"xxx": 1}
+	if items := localCache.Items(); !reflect.DeepEqual(items, expect) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect, items)
+	}
+}
+
+func TestLocalCache_OnThreshold(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(4))
+	defer localCache.Close()
+	var fired []int
+	localCache.OnThreshold(3, func(current int) {
+		fired = append(fired, current)
+	})
+	localCache.MSet(map[localcache.Key]interface{}{"1": 1, "2": 2})
+	localCache.Set("3", 3)
+	localCache.Set("4", 4)
+	localCache.Set("4", 40)
+	if !reflect.DeepEqual(fired, []int{3}) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", []int{3}, fired)
+	}
+	localCache.MDelete([]localcache.Key{"3", "4"})
+	localCache.Set("3", 3)
+	if !reflect.DeepEqual(fired, []int{3, 3}) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", []int{3, 3}, fired)
+	}
+	localCache.Flush()
+	localCache.MSet(map[localcache.Key]interface{}{"1": 1, "2": 2, "3": 3, "4": 4})
+	if len(fired) != 3 || fired[2] < 3 {
+		t.Errorf("err: expect threshold re-armed after Flush, but got: %+v\n", fired)
+	}
+}
+
+func TestLocalCache_GetWithExpireIdle(t *testing.T) {
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithClock(clock), localcache.WithIdleTimeout(time.Minute))
+	defer localCache.Close()
+	localCache.SetWithExpire("xxx", 1, time.Hour)
+	clock.Advance(30 * time.Second)
+	v, ttl, idleLeft, err := localCache.GetWithExpireIdle("xxx")
+	if err != nil || v != 1 || ttl != time.Hour-30*time.Second || idleLeft != time.Minute {
+		t.Errorf("err: unexpected result: %+v, %+v, %+v, %+v\n", v, ttl, idleLeft, err)
+	}
+	clock.Advance(40 * time.Second)
+	if created, accessed, _, _ := localCache.EntryInfo("xxx"); accessed.Sub(created) != 30*time.Second {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 30*time.Second, accessed.Sub(created))
+	}
+	if _, ttl, idleLeft, _ = localCache.GetWithExpireIdle("xxx"); ttl != time.Hour-70*time.Second || idleLeft != time.Minute {
+		t.Errorf("err: unexpected durations after access: %+v, %+v\n", ttl, idleLeft)
+	}
+	var plain = localcache.NewLocalCache(nil)
+	defer plain.Close()
+	plain.Set("xxx", 1)
+	if _, _, idleLeft, _ := plain.GetWithExpireIdle("xxx"); idleLeft != localcache.NeverExpireDuration {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.NeverExpireDuration, idleLeft)
+	}
+}
+
+type customError struct {
+	code int
+}
+
+func (e *customError) Error() string {
+	return fmt.Sprintf("custom error %d", e.code)
+}
+
+func TestLocalCache_GetError(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	stored := &customError{code: 404}
+	localCache.Set("err", stored)
+	localCache.Set("nil", error(nil))
+	localCache.Set("int", 1)
+	v, err := localCache.GetError("err")
+	if err != nil || v != stored {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", stored, v, err)
+	}
+	var custom *customError
+	if !errors.As(v, &custom) || custom.code != 404 {
+		t.Errorf("err: expect custom error, but got: %+v\n", v)
+	}
+	if v, err := localCache.GetError("nil"); v != nil || err != nil {
+		t.Errorf("err: expect nil error, but got: %+v, %+v\n", v, err)
+	}
+	if _, err := localCache.GetError("int"); err != localcache.ErrTypeMismatch {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrTypeMismatch, err)
+	}
+	if _, err := localCache.GetError("missing"); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+}
+
+func TestLocalCache_UpdatePanic(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	localCache.Set("k", 0)
+	func() {
+		defer func() {
+			if r := recover(); r != "bad update" {
+				t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "bad update", r)
+			}
+		}()
+		localCache.Update("k", func(old interface{}, found bool) (interface{}, bool) {
+			panic("bad update")
+		})
+	}()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		localCache.Set("k", 1)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("err: shard locked after panic of Update\n")
+	}
+	if v, _ := localCache.Get("k"); v != 1 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 1, v)
+	}
+}
+
+func TestLocalCache_Update(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	var evicted []localcache.Key
+	localCache.SetEvictedFunc(func(key localcache.Key, entry localcache.Entry) {
+		evicted = append(evicted, key)
+	})
+	appendFn := func(old interface{}, found bool) (interface{}, bool) {
+		if !found {
+			return []string{"a"}, true
+		}
+		return append(old.([]string), "b"), true
+	}
+	if err := localCache.Update("xxx", appendFn); err != nil {
+		t.Error(err)
+	}
+	if err := localCache.Update("xxx", appendFn); err != nil {
+		t.Error(err)
+	}
+	if v, _ := localCache.Get("xxx"); !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", []string{"a", "b"}, v)
+	}
+	if len(evicted) != 0 {
+		t.Errorf("err: expect no evicted key, but got: %+v\n", evicted)
+	}
+	localCache.Update("xxx", func(old interface{}, found bool) (interface{}, bool) {
+		return nil, false
+	})
+	if localCache.Has("xxx") || !reflect.DeepEqual(evicted, []localcache.Key{"xxx"}) {
+		t.Errorf("err: expect key deleted and evicted, but got: %+v\n", evicted)
+	}
+}
+
+func TestLocalCache_KeyStringer(t *testing.T) {
+	var localCache = localcache.New(localcache.WithKeyStringer(func(key localcache.Key) string {
+		return fmt.Sprintf("id:%v", key)
+	}))
+	defer localCache.Close()
+	for _, key := range []int{1, 12, 2} {
+		localCache.Set(key, key)
+	}
+	if s := localCache.KeyString(12); s != "id:12" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", "id:12", s)
+	}
+	if n := localCache.DeletePrefix("id:1"); n != 2 || !localCache.Has(2) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 2, n)
+	}
+	var buf strings.Builder
+	if err := localCache.DumpJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"id:2"`) {
+		t.Errorf("err: expect key rendered by stringer, but got: %+v\n", buf.String())
+	}
+}
+
+func TestLocalCache_StatsBytes(t *testing.T) {
+	var localCache = localcache.New(localcache.WithSizer(func(value interface{}) int64 {
+		return int64(len(value.(string)))
+	}))
+	defer localCache.Close()
+	if avg := localCache.Stats().AvgEntryBytes(); avg != 0 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 0, avg)
+	}
+	localCache.Set("a", "1234")
+	localCache.Set("b", "12")
+	localCache.Set("c", "123456")
+	localCache.Set("b", "12345678")
+	localCache.Delete("c")
+	stats := localCache.Stats()
+	if stats.Bytes != 12 || stats.AvgEntryBytes() != 6 {
+		t.Errorf("err: not equal, expect: %+v, %+v, but got: %+v, %+v\n", 12, 6, stats.Bytes, stats.AvgEntryBytes())
+	}
+	localCache.Flush()
+	if n := localCache.Stats().Bytes; n != 0 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 0, n)
+	}
+}
+
+func TestLocalCache_OnSweep(t *testing.T) {
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithExpireTick(10*time.Millisecond), localcache.WithClock(clock))
+	defer localCache.Close()
+	swept := make(chan int, 100)
+	localCache.OnSweep(func(removed int, duration time.Duration) {
+		if removed > 0 {
+			swept <- removed
+		}
+	})
+	for i := 0; i < 3; i++ {
+		localCache.SetWithExpire(i, i, time.Second)
+	}
+	localCache.Set("forever", 1)
+	clock.Advance(2 * time.Second)
+	select {
+	case n := <-swept:
+		if n != 3 {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 3, n)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("err: expect sweep reported\n")
+	}
+}
+
+func TestLocalCache_AddAll(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(4))
+	defer localCache.Close()
+	if err := localCache.AddAll(map[localcache.Key]interface{}{1: 1, 2: 2}, time.Minute); err != nil {
+		t.Error(err)
+	}
+	err := localCache.AddAll(map[localcache.Key]interface{}{3: 3, 4: 4, 2: 20, 5: 5}, time.Minute)
+	var dup *localcache.DuplicateKeyError
+	if !errors.Is(err, localcache.ErrDuplicateKey) || !errors.As(err, &dup) || dup.Key != 2 {
+		t.Errorf("err: expect duplicate key %+v, but got: %+v\n", 2, err)
+	}
+	for _, key := range []localcache.Key{3, 4, 5} {
+		if localCache.Has(key) {
+			t.Errorf("err: expect key %+v not added\n", key)
+		}
+	}
+	if v, _ := localCache.Get(2); v != 2 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 2, v)
+	}
+}
+
+func TestLocalCache_AddAllReentrant(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(8), localcache.WithMaxEntries(8))
+	defer localCache.Close()
+	var evicted int32
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		atomic.AddInt32(&evicted, 1)
+		for i := 0; i < 64; i++ {
+			localCache.Has(i)
+		}
+	})
+	items := make(map[localcache.Key]interface{})
+	for i := 0; i < 64; i++ {
+		items[i] = i
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		localCache.AddAll(items, time.Minute)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("err: deadlock in evicted callback of AddAll\n")
+	}
+	if atomic.LoadInt32(&evicted) == 0 {
+		t.Errorf("err: expect entries evicted by capacity\n")
+	}
+}
+
+func TestLocalCache_OnCallbackError(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	var recovered []interface{}
+	localCache.OnCallbackError(func(r interface{}) {
+		recovered = append(recovered, r)
+	})
+	localCache.SetEvictedFunc(func(key localcache.Key, entry localcache.Entry) {
+		panic("bad evicted func")
+	})
+	var handled int
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		handled++
+	})
+	localCache.Set("xxx", 1)
+	localCache.Delete("xxx")
+	if !reflect.DeepEqual(recovered, []interface{}{"bad evicted func"}) || handled != 1 {
+		t.Errorf("err: expect panic recovered and other callbacks called, but got: %+v, %+v\n", recovered, handled)
+	}
+	localCache.Set("xxx", 2)
+	if v, err := localCache.Get("xxx"); err != nil || v != 2 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", 2, v, err)
+	}
+	if !localCache.Delete("xxx") || len(recovered) != 2 {
+		t.Errorf("err: expect cache usable after panic, but got: %+v\n", recovered)
+	}
+}
+
+func TestLocalCache_SizerPanic(t *testing.T) {
+	var localCache = localcache.New(localcache.WithSizer(func(value interface{}) int64 {
+		return int64(len(value.(string)))
+	}))
+	defer localCache.Close()
+	var recovered int
+	localCache.OnCallbackError(func(r interface{}) {
+		recovered++
+	})
+	localCache.Set("a", "1234")
+	localCache.Set("b", 1)
+	localCache.MSet(map[localcache.Key]interface{}{"c": 2, "d": "12"})
+	if recovered != 2 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 2, recovered)
+	}
+	if v, err := localCache.Get("b"); err != nil || v != 1 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", 1, v, err)
+	}
+	if n := localCache.Stats().Bytes; n != 6 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 6, n)
+	}
+	clone := localCache.Clone()
+	defer clone.Close()
+	if recovered != 4 || clone.LiveEntries() != 4 {
+		t.Errorf("err: expect panics of clone reported, but got: %+v, %+v\n", recovered, clone.LiveEntries())
+	}
+}
+
+func TestLocalCache_SetKeepTTL(t *testing.T) {
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithClock(clock), localcache.WithExpiration(time.Hour))
+	defer localCache.Close()
+	localCache.SetWithExpire("short", 1, time.Second)
+	localCache.SetWithExpire("forever", 1, 0)
+	clock.Advance(500 * time.Millisecond)
+	localCache.SetKeepTTL("short", 2)
+	localCache.SetKeepTTL("forever", 2)
+	localCache.SetKeepTTL("missing", 2)
+	if v, ttl, err := localCache.GetWithExpire("short"); err != nil || v != 2 || ttl != 500*time.Millisecond {
+		t.Errorf("err: expect original expiry kept, but got: %+v, %+v, %+v\n", v, ttl, err)
+	}
+	if ttl, _ := localCache.TTL("forever"); ttl != localcache.NeverExpireDuration {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.NeverExpireDuration, ttl)
+	}
+	if ttl, _ := localCache.TTL("missing"); ttl != time.Hour {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", time.Hour, ttl)
+	}
+	clock.Advance(time.Second)
+	if localCache.Has("short") {
+		t.Errorf("err: expect key %+v expired\n", "short")
+	}
+}
+
+func TestLocalCache_DerefPointers(t *testing.T) {
+	var localCache = localcache.New(localcache.WithDerefPointers())
+	defer localCache.Close()
+	n, s := 42, "abc"
+	localCache.Set("int", &n)
+	localCache.Set("string", &s)
+	localCache.Set("nil", (*int)(nil))
+	if v, err := localCache.GetInt64("int"); err != nil || v != 42 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", 42, v, err)
+	}
+	if v, err := localCache.GetString("string"); err != nil || v != "abc" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", "abc", v, err)
+	}
+	if _, err := localCache.GetInt64("nil"); err != localcache.ErrNilPointer {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNilPointer, err)
+	}
+	var plain = localcache.NewLocalCache(nil)
+	defer plain.Close()
+	plain.Set("int", &n)
+	if _, err := plain.GetInt64("int"); err != localcache.ErrTypeMismatch {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrTypeMismatch, err)
+	}
+}
+
+func TestLocalCache_TouchMulti(t *testing.T) {
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithClock(clock), localcache.WithShards(4))
+	defer localCache.Close()
+	localCache.SetWithExpire("a", 1, time.Second)
+	localCache.SetWithExpire("b", 2, time.Second)
+	localCache.SetWithExpire("expired", 3, time.Millisecond)
+	clock.Advance(500 * time.Millisecond)
+	errs := localCache.TouchMulti([]localcache.Key{"a", "b", "missing", "expired"}, time.Minute)
+	expect := map[localcache.Key]error{"missing": localcache.ErrNoSuchKey, "expired": localcache.ErrExpiredKey}
+	if !reflect.DeepEqual(errs, expect) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect, errs)
+	}
+	for _, key := range []localcache.Key{"a", "b"} {
+		if ttl, _ := localCache.TTL(key); ttl != time.Minute {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", time.Minute, ttl)
+		}
+	}
+	if errs := localCache.TouchMulti([]localcache.Key{"a"}, time.Hour); errs != nil {
+		t.Errorf("err: expect no error, but got: %+v\n", errs)
+	}
+}
+
+func TestLocalCache_FlushedStats(t *testing.T) {
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithClock(clock))
+	defer localCache.Close()
+	localCache.SetWithExpire("short", 1, time.Second)
+	localCache.MSet(map[localcache.Key]interface{}{"1": 1, "2": 2, "3": 3})
+	clock.Advance(2 * time.Second)
+	localCache.Get("short")
+	localCache.Flush()
+	if stats := localCache.Stats(); stats.Flushed != 3 || stats.Expired != 1 {
+		t.Errorf("err: expect flushed counted apart from expired, but got: %+v\n", stats)
+	}
+	localCache.Reset()
+	if stats := localCache.Stats(); stats.Flushed != 0 || stats.Expired != 0 {
+		t.Errorf("err: expect stats zeroed by Reset, but got: %+v\n", stats)
+	}
+}
+
+func TestLocalCache_SetDefaultExpiration(t *testing.T) {
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithClock(clock), localcache.WithExpiration(time.Minute))
+	defer localCache.Close()
+	localCache.Set("old", 1)
+	localCache.SetDefaultExpiration(time.Hour)
+	localCache.Set("new", 1)
+	localCache.Add("added", 1)
+	if ttl, _ := localCache.TTL("old"); ttl != time.Minute {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", time.Minute, ttl)
+	}
+	for _, key := range []localcache.Key{"new", "added"} {
+		if ttl, _ := localCache.TTL(key); ttl != time.Hour {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", time.Hour, ttl)
+		}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			localCache.SetDefaultExpiration(time.Duration(i+1) * time.Hour)
+			localCache.Set(i, i)
+		}(i)
+	}
+	wg.Wait()
+	localCache.SetDefaultExpiration(2 * time.Minute)
+	clone := localCache.Clone()
+	defer clone.Close()
+	clone.Set("cloned", 1)
+	if ttl, _ := clone.TTL("cloned"); ttl != 2*time.Minute {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 2*time.Minute, ttl)
+	}
+}
+
+func TestLocalCache_GetStale(t *testing.T) {
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithClock(clock), localcache.WithExpireTick(0))
+	defer localCache.Close()
+	localCache.SetWithExpire("xxx", 1, time.Second)
+	if v, stale, err := localCache.GetStale("xxx"); err != nil || stale || v != 1 {
+		t.Errorf("err: expect fresh value, but got: %+v, %+v, %+v\n", v, stale, err)
+	}
+	clock.Advance(2 * time.Second)
+	for i := 0; i < 2; i++ {
+		if v, stale, err := localCache.GetStale("xxx"); err != nil || !stale || v != 1 {
+			t.Errorf("err: expect stale value, but got: %+v, %+v, %+v\n", v, stale, err)
+		}
+	}
+	if _, _, err := localCache.GetStale("missing"); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+	if _, err := localCache.Get("xxx"); err != localcache.ErrExpiredKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrExpiredKey, err)
+	}
+	if _, _, err := localCache.GetStale("xxx"); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: expect removed key absent, but got: %+v\n", err)
+	}
+}
+
+func TestLocalCache_InvalidateTag(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(4))
+	defer localCache.Close()
+	var evicted int32
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		if reason == localcache.EvictDeleted {
+			atomic.AddInt32(&evicted, 1)
+		}
+	})
+	localCache.SetWithTags("profile", 1, time.Minute, "user:123")
+	localCache.SetWithTags("orders", 2, time.Minute, "user:123", "orders")
+	localCache.SetWithTags("other", 3, time.Minute, "user:456", "orders")
+	localCache.SetWithTags("retagged", 4, time.Minute, "user:123")
+	localCache.Set("retagged", 4)
+	localCache.Set("plain", 5)
+	if n := localCache.InvalidateTag("user:123"); n != 2 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 2, n)
+	}
+	for key, expect := range map[localcache.Key]bool{"profile": false, "orders": false, "other": true, "retagged": true, "plain": true} {
+		if localCache.Has(key) != expect {
+			t.Errorf("err: not equal, key: %+v, expect: %+v, but got: %+v\n", key, expect, !expect)
+		}
+	}
+	if n := atomic.LoadInt32(&evicted); n != 2 {
+		t.Errorf("err: not equal, expect: %+v evictions, but got: %+v\n", 2, n)
+	}
+	if n := localCache.InvalidateTag("orders"); n != 1 || localCache.Has("other") {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 1, n)
+	}
+	if n := localCache.InvalidateTag("user:123"); n != 0 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 0, n)
+	}
+	tags := []string{"mutated"}
+	localCache.SetWithTags("mutated", 6, time.Minute, tags...)
+	tags[0] = "other"
+	localCache.Set("mutated", 6)
+	if n := localCache.InvalidateTag("mutated"); n != 0 || !localCache.Has("mutated") {
+		t.Errorf("err: expect tags copied and dropped by Set, but got: %+v\n", n)
+	}
+}
+
+func TestLocalCache_DebugString(t *testing.T) {
+	var localCache = localcache.New(localcache.WithMaxEntries(100), localcache.WithExpiration(time.Minute))
+	defer localCache.Close()
+	localCache.Set("xxx", 1)
+	localCache.Get("xxx")
+	localCache.Get("xxx")
+	localCache.Get("missing")
+	stats := localCache.Stats()
+	if s := stats.String(); s != "entries=1 hits=2 misses=1 hitRatio=0.67 expired=0 flushed=0 total=1" {
+		t.Errorf("err: unexpected stats string: %+v\n", s)
+	}
+	s := localCache.DebugString()
+	for _, field := range []string{"live=1", "maxEntries=100", "policy=lru", "expiration=1m0s", "hits=2", "hitRatio=0.67"} {
+		if !strings.Contains(s, field) {
+			t.Errorf("err: expect %+v in debug string, but got: %+v\n", field, s)
+		}
+	}
+}
+
+func TestLocalCache_TopKeys(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(4), localcache.WithTrackKeyHits())
+	defer localCache.Close()
+	for i := 0; i < 20; i++ {
+		localCache.Set(fmt.Sprint(i), i)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				localCache.Get("hot")
+				localCache.Get("3")
+				localCache.Get("3")
+				localCache.Get(fmt.Sprint(i % 20))
+			}
+		}()
+	}
+	wg.Wait()
+	localCache.Peek("0")
+	expect := []localcache.KeyHits{{Key: "3", Hits: 820}}
+	if top := localCache.TopKeys(1); !reflect.DeepEqual(top, expect) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect, top)
+	}
+	if top := localCache.TopKeys(30); len(top) != 20 || top[1].Hits != 20 {
+		t.Errorf("err: expect 20 keys hit and missing key not counted, but got: %+v\n", top)
+	}
+	localCache.ResetKeyHits()
+	if top := localCache.TopKeys(1); len(top) != 0 {
+		t.Errorf("err: expect no keys after reset, but got: %+v\n", top)
+	}
+}
+
+func TestLocalCache_OldestEntries(t *testing.T) {
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithClock(clock), localcache.WithShards(4))
+	defer localCache.Close()
+	for i, ttl := range []time.Duration{5 * time.Second, time.Second, 4 * time.Second, 2 * time.Second, 3 * time.Second} {
+		localCache.SetWithExpire(i, i, ttl)
+	}
+	localCache.SetWithExpire("forever", 1, 0)
+	localCache.SetWithExpire("expired", 1, time.Millisecond)
+	clock.Advance(10 * time.Millisecond)
+	entries := localCache.OldestEntries(3)
+	expect := []localcache.Key{1, 3, 4}
+	if len(entries) != len(expect) {
+		t.Fatalf("err: not equal, expect: %+v entries, but got: %+v\n", len(expect), entries)
+	}
+	for i, entry := range entries {
+		if entry.Key != expect[i] {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect[i], entry.Key)
+		}
+	}
+	if at := entries[0].ExpireAt; !at.Equal(clock.Now().Add(time.Second - 10*time.Millisecond)) {
+		t.Errorf("err: unexpected expire time: %+v\n", at)
+	}
+	if n := len(localCache.OldestEntries(100)); n != 5 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 5, n)
+	}
+}
+
+func TestLocalCache_TTLMulti(t *testing.T) {
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithClock(clock), localcache.WithShards(4))
+	defer localCache.Close()
+	localCache.SetWithExpire("live", 1, time.Minute)
+	localCache.SetWithExpire("forever", 1, 0)
+	localCache.SetWithExpire("expired", 1, time.Second)
+	clock.Advance(2 * time.Second)
+	ttls := localCache.TTLMulti([]localcache.Key{"live", "forever", "expired", "missing"})
+	expect := map[localcache.Key]time.Duration{
+		"live":    time.Minute - 2*time.Second,
+		"forever": localcache.NeverExpireDuration,
+		"expired": localcache.ExpireDuration,
+		"missing": localcache.ExpireDuration,
+	}
+	if !reflect.DeepEqual(ttls, expect) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect, ttls)
+	}
+	if stats := localCache.Stats(); stats.Hits != 0 || stats.Misses != 0 || stats.Entries != 3 {
+		t.Errorf("err: expect stats and entries untouched, but got: %+v\n", stats)
+	}
 }
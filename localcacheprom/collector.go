@@ -0,0 +1,73 @@
+// Package localcacheprom export localcache stats as prometheus metrics.
+package localcacheprom
+
+import (
+	"sync"
+
+	"github.com/leaxoy/localcache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var reasons = []localcache.EvictReason{
+	localcache.EvictExpired,
+	localcache.EvictDeleted,
+	localcache.EvictReplaced,
+	localcache.EvictCapacity,
+}
+
+// Collector is a prometheus.Collector which read stats of a LocalCache on each scrape.
+type Collector struct {
+	cache *localcache.LocalCache
+
+	entries *prometheus.Desc
+	hits    *prometheus.Desc
+	misses  *prometheus.Desc
+	expired *prometheus.Desc
+	evicted *prometheus.Desc
+
+	mu        sync.Mutex
+	evictions map[localcache.EvictReason]float64
+}
+
+// NewCollector return a Collector of cache, metrics name will be prefixed by namespace.
+// It register an OnEvict callback on cache to count evictions by reason.
+func NewCollector(cache *localcache.LocalCache, namespace string) *Collector {
+	c := &Collector{
+		cache:     cache,
+		entries:   prometheus.NewDesc(prometheus.BuildFQName(namespace, "localcache", "entries"), "Number of entries in cache.", nil, nil),
+		hits:      prometheus.NewDesc(prometheus.BuildFQName(namespace, "localcache", "hits_total"), "Number of cache hits.", nil, nil),
+		misses:    prometheus.NewDesc(prometheus.BuildFQName(namespace, "localcache", "misses_total"), "Number of cache misses.", nil, nil),
+		expired:   prometheus.NewDesc(prometheus.BuildFQName(namespace, "localcache", "expired_total"), "Number of expired entries.", nil, nil),
+		evicted:   prometheus.NewDesc(prometheus.BuildFQName(namespace, "localcache", "evicted_total"), "Number of evicted entries by reason.", []string{"reason"}, nil),
+		evictions: make(map[localcache.EvictReason]float64),
+	}
+	cache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		c.mu.Lock()
+		c.evictions[reason]++
+		c.mu.Unlock()
+	})
+	return c
+}
+
+// Describe implement prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.entries
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.expired
+	ch <- c.evicted
+}
+
+// Collect implement prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.cache.Stats()
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(stats.Entries))
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.expired, prometheus.CounterValue, float64(stats.Expired))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, reason := range reasons {
+		ch <- prometheus.MustNewConstMetric(c.evicted, prometheus.CounterValue, c.evictions[reason], reason.String())
+	}
+}
@@ -0,0 +1,52 @@
+package localcacheprom_test
+
+import (
+	"testing"
+
+	"github.com/leaxoy/localcache"
+	"github.com/leaxoy/localcache/localcacheprom"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollector(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(localcacheprom.NewCollector(localCache, "test")); err != nil {
+		t.Fatal(err)
+	}
+	localCache.Set("xxx", 1)
+	localCache.Set("yyy", 1)
+	localCache.Get("xxx")
+	localCache.Get("zzz")
+	localCache.Delete("yyy")
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			name := family.GetName()
+			for _, label := range m.GetLabel() {
+				name += ":" + label.GetValue()
+			}
+			if m.GetCounter() != nil {
+				values[name] = m.GetCounter().GetValue()
+			} else {
+				values[name] = m.GetGauge().GetValue()
+			}
+		}
+	}
+	for name, value := range map[string]float64{
+		"test_localcache_entries":               1,
+		"test_localcache_hits_total":            1,
+		"test_localcache_misses_total":          1,
+		"test_localcache_expired_total":         0,
+		"test_localcache_evicted_total:deleted": 1,
+		"test_localcache_evicted_total:expired": 0,
+	} {
+		if v, ok := values[name]; !ok || v != value {
+			t.Errorf("err: not equal, metric: %+v, expect: %+v, but got: %+v\n", name, value, v)
+		}
+	}
+}
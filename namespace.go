@@ -0,0 +1,74 @@
+package localcache
+
+import "time"
+
+// NamespacedKey is the key stored in the underlying cache by a NamespacedCache,
+// evicted func and OnEvict callbacks receive it as key of namespaced entries.
+type NamespacedKey struct {
+	Namespace string
+	Key       Key
+}
+
+// NamespacedCache is a view of LocalCache with an isolated keyspace, it shares entries, stats,
+// expiration and background goroutines with the underlying cache.
+type NamespacedCache struct {
+	cache  *LocalCache
+	prefix string
+}
+
+// Namespace return a view of cache whose keys are isolated from other namespaces and plain keys.
+func (c *LocalCache) Namespace(prefix string) *NamespacedCache {
+	return &NamespacedCache{cache: c, prefix: prefix}
+}
+
+func (n *NamespacedCache) key(key Key) Key {
+	return NamespacedKey{Namespace: n.prefix, Key: key}
+}
+
+// Add will do same as LocalCache.Add in namespace.
+func (n *NamespacedCache) Add(key Key, value interface{}) error {
+	return n.cache.Add(n.key(key), value)
+}
+
+// AddWithExpire will do same as LocalCache.AddWithExpire in namespace.
+func (n *NamespacedCache) AddWithExpire(key Key, value interface{}, duration time.Duration) error {
+	return n.cache.AddWithExpire(n.key(key), value, duration)
+}
+
+// Set will do same as LocalCache.Set in namespace.
+func (n *NamespacedCache) Set(key Key, value interface{}) {
+	n.cache.Set(n.key(key), value)
+}
+
+// SetWithExpire will do same as LocalCache.SetWithExpire in namespace.
+func (n *NamespacedCache) SetWithExpire(key Key, value interface{}, duration time.Duration) {
+	n.cache.SetWithExpire(n.key(key), value, duration)
+}
+
+// Has will do same as LocalCache.Has in namespace.
+func (n *NamespacedCache) Has(key Key) bool {
+	return n.cache.Has(n.key(key))
+}
+
+// Get will do same as LocalCache.Get in namespace.
+func (n *NamespacedCache) Get(key Key) (interface{}, error) {
+	return n.cache.Get(n.key(key))
+}
+
+// GetWithExpire will do same as LocalCache.GetWithExpire in namespace.
+func (n *NamespacedCache) GetWithExpire(key Key) (interface{}, time.Duration, error) {
+	return n.cache.GetWithExpire(n.key(key))
+}
+
+// Delete will do same as LocalCache.Delete in namespace.
+func (n *NamespacedCache) Delete(key Key) bool {
+	return n.cache.Delete(n.key(key))
+}
+
+// Flush remove all entries of namespace and return how many have been removed, other keys are untouched.
+func (n *NamespacedCache) Flush() int {
+	return n.cache.DeleteFunc(func(key Key, value interface{}) bool {
+		nk, ok := key.(NamespacedKey)
+		return ok && nk.Namespace == n.prefix
+	})
+}
@@ -0,0 +1,28 @@
+package localcache_test
+
+import (
+	"testing"
+
+	"github.com/leaxoy/localcache"
+)
+
+func TestLocalCache_Namespace(t *testing.T) {
+	var localCache = localcache.New(localcache.WithShards(4))
+	defer localCache.Close()
+	users, orders := localCache.Namespace("users"), localCache.Namespace("orders")
+	users.Set("1", "alice")
+	orders.Set("1", "book")
+	localCache.Set("1", "plain")
+	if v, err := users.Get("1"); err != nil || v != "alice" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", "alice", v, err)
+	}
+	if v, err := orders.Get("1"); err != nil || v != "book" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", "book", v, err)
+	}
+	if n := users.Flush(); n != 1 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 1, n)
+	}
+	if users.Has("1") || !orders.Has("1") || !localCache.Has("1") {
+		t.Errorf("err: expect only users namespace flushed\n")
+	}
+}
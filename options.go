@@ -0,0 +1,219 @@
+package localcache
+
+import "time"
+
+// Option configure a LocalCache created by New.
+type Option func(*CacheConfig)
+
+// New return a empty LocalCache configured by opts on top of NewCacheConfig.
+func New(opts ...Option) *LocalCache {
+	config := NewCacheConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	return newLocalCache(config)
+}
+
+// WithConfig replace the whole config with a copy of config.
+func WithConfig(config *CacheConfig) Option {
+	return func(c *CacheConfig) {
+		*c = *config
+	}
+}
+
+// WithExpiration set the default expiration, d <= 0 means never expire.
+func WithExpiration(d time.Duration) Option {
+	return func(c *CacheConfig) {
+		c.Expiration = d
+	}
+}
+
+// WithExpireTick set the interval of background expire sweep, d <= 0 disable the sweep.
+func WithExpireTick(d time.Duration) Option {
+	return func(c *CacheConfig) {
+		c.ExpireTick = d
+	}
+}
+
+// WithShards set the number of shards.
+func WithShards(n int) Option {
+	return func(c *CacheConfig) {
+		c.Shards = n
+	}
+}
+
+// WithExpireJitter set the random range of expiration.
+func WithExpireJitter(d time.Duration) Option {
+	return func(c *CacheConfig) {
+		c.ExpireJitter = d
+	}
+}
+
+// WithAsyncEvict dispatch eviction callbacks asynchronously with a queue of buffer size.
+func WithAsyncEvict(buffer int) Option {
+	return func(c *CacheConfig) {
+		c.AsyncEvict = true
+		c.EvictBuffer = buffer
+	}
+}
+
+// WithMaxEntries limit the number of entries, n <= 0 means unlimited.
+func WithMaxEntries(n int) Option {
+	return func(c *CacheConfig) {
+		c.MaxEntries = n
+	}
+}
+
+// WithEvictionPolicy set the policy used to evict entries when MaxEntries reached.
+func WithEvictionPolicy(p EvictionPolicy) Option {
+	return func(c *CacheConfig) {
+		c.EvictionPolicy = p
+	}
+}
+
+// WithEvictedFunc set the evicted func, same as calling SetEvictedFunc after creation.
+func WithEvictedFunc(fn func(Key, Entry)) Option {
+	return func(c *CacheConfig) {
+		c.EvictedFunc = fn
+	}
+}
+
+// WithSliding extend the life of an entry on every successful read.
+func WithSliding() Option {
+	return func(c *CacheConfig) {
+		c.Sliding = true
+	}
+}
+
+// WithMaxValueBytes reject values larger than n bytes reported by sizer.
+func WithMaxValueBytes(n int64, sizer Sizer) Option {
+	return func(c *CacheConfig) {
+		c.MaxValueBytes = n
+		c.Sizer = sizer
+	}
+}
+
+// WithSizer set the sizer used to measure values for MaxValueBytes and the Bytes of stats.
+func WithSizer(sizer Sizer) Option {
+	return func(c *CacheConfig) {
+		c.Sizer = sizer
+	}
+}
+
+// WithExpireBudget limit the number of expired entries removed from a shard by one sweep.
+func WithExpireBudget(n int) Option {
+	return func(c *CacheConfig) {
+		c.ExpireBudget = n
+	}
+}
+
+// WithIdleTimeout expire entries which have not been read for d.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(c *CacheConfig) {
+		c.IdleTimeout = d
+	}
+}
+
+// WithRefreshAhead reload entries by loader in background when they are read within d of expiration.
+func WithRefreshAhead(d time.Duration, loader func(key Key) (interface{}, error)) Option {
+	return func(c *CacheConfig) {
+		c.RefreshAhead = d
+		c.Loader = loader
+	}
+}
+
+// WithClock set the Clock used to compute and check expiration.
+func WithClock(clock Clock) Option {
+	return func(c *CacheConfig) {
+		c.Clock = clock
+	}
+}
+
+// WithProtectedRatio set the ratio of protected segment of PolicySLRU.
+func WithProtectedRatio(ratio float64) Option {
+	return func(c *CacheConfig) {
+		c.ProtectedRatio = ratio
+	}
+}
+
+// WithEvictionSampleSize set the number of entries sampled to choose a victim by PolicySampledLRU.
+func WithEvictionSampleSize(n int) Option {
+	return func(c *CacheConfig) {
+		c.EvictionSampleSize = n
+	}
+}
+
+// WithTrackKeyHits count hits of every key for TopKeys.
+func WithTrackKeyHits() Option {
+	return func(c *CacheConfig) {
+		c.TrackKeyHits = true
+	}
+}
+
+// WithTrackLatency record the count and latency of lookups and sets in stats.
+func WithTrackLatency() Option {
+	return func(c *CacheConfig) {
+		c.TrackLatency = true
+	}
+}
+
+// WithKeyStringer set the func to render keys as string, default is fmt.Sprint.
+func WithKeyStringer(fn func(Key) string) Option {
+	return func(c *CacheConfig) {
+		c.KeyStringer = fn
+	}
+}
+
+// WithDerefPointers make GetXXX func dereference a pointer value once.
+func WithDerefPointers() Option {
+	return func(c *CacheConfig) {
+		c.DerefPointers = true
+	}
+}
+
+// WithMaxConcurrentLoads limit the number of loader calls running at the same time, loads beyond n fail
+// with ErrTooManyLoads if failFast, or wait for a slot otherwise.
+func WithMaxConcurrentLoads(n int, failFast bool) Option {
+	return func(c *CacheConfig) {
+		c.MaxConcurrentLoads = n
+		c.LoadFailFast = failFast
+	}
+}
+
+// WithCopyOnRead make methods returning values by key return a deep copy of value made by encoding/gob.
+func WithCopyOnRead() Option {
+	return func(c *CacheConfig) {
+		c.CopyOnRead = true
+	}
+}
+
+// WithInitialCapacity size the cache for n entries up front.
+func WithInitialCapacity(n int) Option {
+	return func(c *CacheConfig) {
+		c.InitialCapacity = n
+	}
+}
+
+// WithWriteBack enable write-back mode, dirty values are passed to sink in batches of at most batch keys
+// every interval.
+func WithWriteBack(sink Sink, interval time.Duration, batch int) Option {
+	return func(c *CacheConfig) {
+		c.WriteBack = sink
+		c.WriteBackInterval = interval
+		c.WriteBackBatch = batch
+	}
+}
+
+// WithCodec set the Codec used by Save and Load to serialize keys and values.
+func WithCodec(codec Codec) Option {
+	return func(c *CacheConfig) {
+		c.Codec = codec
+	}
+}
+
+// WithSoftLimit evict entries on every sweep while the heap in use exceed n bytes.
+func WithSoftLimit(n uint64) Option {
+	return func(c *CacheConfig) {
+		c.SoftLimit = n
+	}
+}
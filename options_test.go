@@ -0,0 +1,55 @@
+package localcache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leaxoy/localcache"
+)
+
+func TestNew(t *testing.T) {
+	var evicted []localcache.Key
+	var localCache = localcache.New(
+		localcache.WithExpiration(time.Minute),
+		localcache.WithExpireTick(time.Second),
+		localcache.WithMaxEntries(2),
+		localcache.WithEvictionPolicy(localcache.PolicyLRU),
+		localcache.WithEvictedFunc(func(key localcache.Key, entry localcache.Entry) {
+			evicted = append(evicted, key)
+		}),
+	)
+	defer localCache.Close()
+	localCache.Set("a", 1)
+	localCache.Set("b", 2)
+	localCache.Get("a")
+	localCache.Set("c", 3)
+	if localCache.Has("b") || !localCache.Has("a") || !localCache.Has("c") {
+		t.Errorf("err: expect least recently used key %+v evicted\n", "b")
+	}
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", []string{"b"}, evicted)
+	}
+	if ttl, _ := localCache.TTL("a"); ttl > time.Minute || ttl < time.Minute-time.Second {
+		t.Errorf("err: ttl out of range, got: %+v\n", ttl)
+	}
+}
+
+func TestNew_FIFO(t *testing.T) {
+	var reasons []localcache.EvictReason
+	var localCache = localcache.New(localcache.WithMaxEntries(2), localcache.WithEvictionPolicy(localcache.PolicyFIFO))
+	defer localCache.Close()
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		reasons = append(reasons, reason)
+	})
+	localCache.Set("a", 1)
+	localCache.Set("b", 2)
+	localCache.Get("a")
+	localCache.Set("a", 3)
+	localCache.Set("c", 4)
+	if localCache.Has("a") || !localCache.Has("b") || !localCache.Has("c") {
+		t.Errorf("err: expect first inserted key %+v evicted\n", "a")
+	}
+	if len(reasons) != 2 || reasons[0] != localcache.EvictReplaced || reasons[1] != localcache.EvictCapacity {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", []localcache.EvictReason{localcache.EvictReplaced, localcache.EvictCapacity}, reasons)
+	}
+}
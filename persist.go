@@ -0,0 +1,123 @@
+package localcache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"sync/atomic"
+)
+
+// snapshotEntry is the persistent form of an entry.
+type snapshotEntry struct {
+	Key    Key
+	Value  interface{}
+	Expire int64
+}
+
+// jsonEntry is the json form of an entry.
+type jsonEntry struct {
+	Value    interface{} `json:"value"`
+	ExpireAt int64       `json:"expireAt"`
+}
+
+// snapshot collect all live entries except negative cached ones.
+func (c *LocalCache) snapshot() []snapshotEntry {
+	var entries []snapshotEntry
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for key, e := range s.data {
+			if !e.IsExpired() && !e.isNegative() {
+				entries = append(entries, snapshotEntry{Key: key, Value: e.value, Expire: e.expire})
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return entries
+}
+
+// restore store entries with their absolute expire time, expired ones will be skipped.
+func (c *LocalCache) restore(entries []snapshotEntry) {
+	now := c.clock.Now().UnixNano()
+	for _, se := range entries {
+		if se.Expire != 0 && se.Expire < now {
+			continue
+		}
+		s := c.shard(se.Key)
+		s.mu.Lock()
+		c.store(s, se.Key, Entry{value: se.Value, expire: se.Expire})
+		atomic.AddInt64(&s.stats.Total, 1)
+		c.unlock(s)
+	}
+}
+
+// Save write all live entries with their absolute expire time to w using encoding/gob.
+// Keys and values are stored as interface{}, so callers must gob.Register their concrete types.
+// If Codec configured, keys and values are serialized by it instead, and Load of a cache with
+// the same Codec must be used to read them.
+func (c *LocalCache) Save(w io.Writer) error {
+	if c.config.Codec == nil {
+		return gob.NewEncoder(w).Encode(c.snapshot())
+	}
+	encoded, err := encodeEntries(c.config.Codec, c.snapshot())
+	if err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(encoded)
+}
+
+// Load read entries written by Save from r and store them into cache, existing keys will be overwritten.
+// Entries which have expired during persistence will be skipped.
+func (c *LocalCache) Load(r io.Reader) error {
+	if c.isClosed() {
+		return ErrCacheClosed
+	}
+	if c.config.Codec != nil {
+		var encoded []codecEntry
+		if err := gob.NewDecoder(r).Decode(&encoded); err != nil {
+			return err
+		}
+		entries, err := decodeEntries(c.config.Codec, encoded)
+		if err != nil {
+			return err
+		}
+		c.restore(entries)
+		return nil
+	}
+	var entries []snapshotEntry
+	if err := gob.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+	c.restore(entries)
+	return nil
+}
+
+// DumpJSON write all live entries to w as a json object of key to {"value", "expireAt"},
+// expireAt is the absolute expire time in unix nano, 0 means never expire.
+// Keys are rendered by KeyString, so keys with the same string form will overwrite each other.
+func (c *LocalCache) DumpJSON(w io.Writer) error {
+	entries := c.snapshot()
+	m := make(map[string]jsonEntry, len(entries))
+	for _, se := range entries {
+		m[c.KeyString(se.Key)] = jsonEntry{Value: se.Value, ExpireAt: se.Expire}
+	}
+	return json.NewEncoder(w).Encode(m)
+}
+
+// LoadJSON read entries written by DumpJSON from r and store them into cache with string keys.
+// Values are decoded by encoding/json into interface{}, so numbers become float64.
+// Entries which have expired will be skipped.
+func (c *LocalCache) LoadJSON(r io.Reader) error {
+	if c.isClosed() {
+		return ErrCacheClosed
+	}
+	var m map[string]jsonEntry
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return err
+	}
+	entries := make([]snapshotEntry, 0, len(m))
+	for key, je := range m {
+		entries = append(entries, snapshotEntry{Key: key, Value: je.Value, Expire: je.ExpireAt})
+	}
+	c.restore(entries)
+	return nil
+}
@@ -0,0 +1,127 @@
+package localcache_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/leaxoy/localcache"
+)
+
+type persistValue struct {
+	Name string
+	Tags []string
+}
+
+func init() {
+	gob.Register(persistValue{})
+}
+
+func TestLocalCache_SaveLoad(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.SetWithExpire("int", 1, time.Minute)
+	localCache.SetWithExpire("forever", "abc", 0)
+	localCache.SetWithExpire(42, persistValue{"x", []string{"a", "b"}}, time.Hour)
+	localCache.SetWithExpire("short", 1, 50*time.Millisecond)
+	var buf bytes.Buffer
+	if err := localCache.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	var loaded = localcache.NewLocalCache(nil)
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[localcache.Key]interface{}{
+		"int":     1,
+		"forever": "abc",
+		42:        persistValue{"x", []string{"a", "b"}},
+	} {
+		v, err := loaded.Get(key)
+		if err != nil {
+			t.Error(err)
+		}
+		if !reflect.DeepEqual(v, value) {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", value, v)
+		}
+	}
+	if ttl, _ := loaded.TTL("int"); ttl > time.Minute || ttl < time.Minute-time.Second {
+		t.Errorf("err: ttl out of range, got: %+v\n", ttl)
+	}
+	if ttl, _ := loaded.TTL("forever"); ttl != localcache.NeverExpireDuration {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.NeverExpireDuration, ttl)
+	}
+	if loaded.Has("short") {
+		t.Errorf("err: expect key %+v skipped\n", "short")
+	}
+}
+
+func TestLocalCache_DumpLoadJSON(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	localCache.SetWithExpire("string", "abc", time.Minute)
+	localCache.SetWithExpire(1, 1.5, 0)
+	localCache.SetWithExpire("map", map[string]interface{}{"a": true}, time.Hour)
+	localCache.SetWithExpire("short", 1, 50*time.Millisecond)
+	var buf bytes.Buffer
+	if err := localCache.DumpJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	var loaded = localcache.NewLocalCache(nil)
+	if err := loaded.LoadJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[localcache.Key]interface{}{
+		"string": "abc",
+		"1":      1.5,
+		"map":    map[string]interface{}{"a": true},
+	} {
+		v, err := loaded.Get(key)
+		if err != nil {
+			t.Error(err)
+		}
+		if !reflect.DeepEqual(v, value) {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", value, v)
+		}
+	}
+	if ttl, _ := loaded.TTL("string"); ttl > time.Minute || ttl < time.Minute-time.Second {
+		t.Errorf("err: ttl out of range, got: %+v\n", ttl)
+	}
+	if loaded.Has("short") {
+		t.Errorf("err: expect key %+v skipped\n", "short")
+	}
+}
+
+func TestLocalCache_Codec(t *testing.T) {
+	for _, tc := range []struct {
+		codec  localcache.Codec
+		key    localcache.Key
+		value  interface{}
+		expect interface{}
+	}{
+		{localcache.GobCodec{}, 42, persistValue{"x", []string{"a", "b"}}, persistValue{"x", []string{"a", "b"}}},
+		{localcache.JSONCodec{}, "json", persistValue{"x", []string{"a", "b"}},
+			map[string]interface{}{"Name": "x", "Tags": []interface{}{"a", "b"}}},
+	} {
+		var localCache = localcache.New(localcache.WithCodec(tc.codec))
+		localCache.SetWithExpire(tc.key, tc.value, time.Minute)
+		var buf bytes.Buffer
+		if err := localCache.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		localCache.Close()
+		var loaded = localcache.New(localcache.WithCodec(tc.codec))
+		if err := loaded.Load(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if v, err := loaded.Get(tc.key); err != nil || !reflect.DeepEqual(v, tc.expect) {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", tc.expect, v, err)
+		}
+		if ttl, _ := loaded.TTL(tc.key); ttl > time.Minute || ttl < time.Minute-time.Second {
+			t.Errorf("err: ttl out of range, got: %+v\n", ttl)
+		}
+		loaded.Close()
+	}
+}
@@ -0,0 +1,224 @@
+package localcache
+
+import (
+	"container/list"
+	"runtime"
+	"sync/atomic"
+)
+
+// EvictionPolicy decide which entry will be evicted when cache is full.
+type EvictionPolicy int
+
+const (
+	// PolicyLRU evict the least recently used entry, both Get and Set count as use.
+	PolicyLRU EvictionPolicy = iota
+	// PolicyFIFO evict the earliest inserted entry, overwriting a key keep its position.
+	PolicyFIFO
+	// PolicySLRU is segmented LRU, new entries start in a probationary segment and are promoted to a
+	// protected segment on a second hit, entries are evicted from the probationary segment first.
+	// It keeps frequently used entries from being flushed by a scan of cold keys.
+	PolicySLRU
+	// PolicySampledLRU is approximate LRU, it evict the least recently used one of EvictionSampleSize
+	// random entries. Reads only update the access time of entry, so they do not need the write lock.
+	PolicySampledLRU
+)
+
+const (
+	defaultProtectedRatio = 0.8
+	defaultSampleSize     = 5
+)
+
+// String return the name of policy.
+func (p EvictionPolicy) String() string {
+	switch p {
+	case PolicyLRU:
+		return "lru"
+	case PolicyFIFO:
+		return "fifo"
+	case PolicySLRU:
+		return "slru"
+	case PolicySampledLRU:
+		return "sampled-lru"
+	}
+	return "unknown"
+}
+
+// shardCapacity split maxEntries over n shards, 0 means unlimited.
+func shardCapacity(maxEntries, n int) int {
+	if maxEntries <= 0 {
+		return 0
+	}
+	return (maxEntries + n - 1) / n
+}
+
+// protectedCapacity return the max entries of protected segment of PolicySLRU.
+func protectedCapacity(capacity int, ratio float64) int {
+	if ratio <= 0 || ratio >= 1 {
+		ratio = defaultProtectedRatio
+	}
+	return int(float64(capacity) * ratio)
+}
+
+// promotes report whether reads change the eviction order.
+func (s *shard) promotes() bool {
+	return s.order != nil && s.policy != PolicyFIFO
+}
+
+// promote mark entry of key as recently used by policy and return the updated entry,
+// the caller must hold the write lock.
+func (s *shard) promote(key Key, entry Entry) Entry {
+	if !s.promotes() || entry.elem == nil {
+		return entry
+	}
+	if s.policy != PolicySLRU || entry.protected {
+		s.list(entry).MoveToFront(entry.elem)
+		return entry
+	}
+	s.order.Remove(entry.elem)
+	entry.elem = s.protected.PushFront(key)
+	entry.protected = true
+	s.data[key] = entry
+	if s.protected.Len() > s.protectedCap {
+		back := s.protected.Back()
+		k := back.Value.(Key)
+		demoted := s.data[k]
+		s.protected.Remove(back)
+		demoted.elem = s.order.PushFront(k)
+		demoted.protected = false
+		s.data[k] = demoted
+	}
+	return s.data[key]
+}
+
+// list return the eviction order list which entry belongs to.
+func (s *shard) list(entry Entry) *list.List {
+	if entry.protected {
+		return s.protected
+	}
+	return s.order
+}
+
+// track add key to eviction order or update its position, return the element and whether it is in
+// the protected segment. The caller must hold the write lock.
+func (s *shard) track(key Key, old Entry) (*list.Element, bool) {
+	if s.order == nil {
+		return nil, false
+	}
+	if old.elem == nil {
+		return s.order.PushFront(key), false
+	}
+	if s.policy != PolicyFIFO {
+		s.list(old).MoveToFront(old.elem)
+	}
+	return old.elem, old.protected
+}
+
+// victim return the key which should be evicted first by policy, keys of a shard without eviction order
+// are chosen in map order. ok is false if s is empty. The caller must hold the lock.
+func (s *shard) victim() (key Key, ok bool) {
+	if s.policy == PolicySampledLRU {
+		return s.sample()
+	}
+	if s.order != nil {
+		back := s.order.Back()
+		if back == nil && s.protected != nil {
+			back = s.protected.Back()
+		}
+		if back == nil {
+			return nil, false
+		}
+		return back.Value.(Key), true
+	}
+	for key := range s.data {
+		return key, true
+	}
+	return nil, false
+}
+
+// sample return the least recently accessed one of s.samples entries, map iteration start at a random
+// position so the entries are sampled randomly. The caller must hold the lock.
+func (s *shard) sample() (key Key, ok bool) {
+	var oldest int64
+	n := 0
+	for k, entry := range s.data {
+		var accessed int64
+		if entry.meta != nil {
+			accessed = atomic.LoadInt64(&entry.meta.accessed)
+		}
+		if !ok || accessed < oldest {
+			key, oldest, ok = k, accessed, true
+		}
+		if n++; n >= s.samples {
+			break
+		}
+	}
+	return
+}
+
+// evictVictim evict the victim of s for capacity and report whether there is one,
+// the caller must hold the write lock of s.
+func (c *LocalCache) evictVictim(s *shard) bool {
+	key, ok := s.victim()
+	if !ok {
+		return false
+	}
+	c.evict(s, key, s.data[key], EvictCapacity)
+	c.emit(s, key, OpEvict, s.data[key])
+	s.remove(key)
+	return true
+}
+
+// ensureCapacity evict entries by policy until there is room for a new key,
+// the caller must hold the write lock of s.
+func (c *LocalCache) ensureCapacity(s *shard) {
+	for s.capacity > 0 && len(s.data) >= s.capacity {
+		c.evictVictim(s)
+	}
+}
+
+// TrimToSize evict entries by EvictionPolicy until at most n remain and return how many have been evicted,
+// entries are chosen arbitrarily if MaxEntries not configured. Every shard evict its share of the excess,
+// so the order is by policy within a shard only. Evictions are reported with EvictCapacity.
+func (c *LocalCache) TrimToSize(n int) int {
+	if n < 0 {
+		n = 0
+	}
+	removed := 0
+	for {
+		total := int(atomic.LoadInt64(&c.size))
+		if total <= n {
+			return removed
+		}
+		progress := false
+		for _, s := range c.shards {
+			s.mu.Lock()
+			share := ((total-n)*len(s.data) + total - 1) / total
+			for ; share > 0 && int(atomic.LoadInt64(&c.size)) > n && c.evictVictim(s); share-- {
+				removed++
+				progress = true
+			}
+			c.unlock(s)
+		}
+		if !progress {
+			return removed
+		}
+	}
+}
+
+// enforceSoftLimit evict a tenth of entries of every shard by policy if the heap in use exceed SoftLimit.
+func (c *LocalCache) enforceSoftLimit() {
+	if c.config.SoftLimit == 0 {
+		return
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	if ms.HeapAlloc <= c.config.SoftLimit {
+		return
+	}
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for n := len(s.data)/10 + 1; n > 0 && c.evictVictim(s); n-- {
+		}
+		c.unlock(s)
+	}
+}
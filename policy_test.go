@@ -0,0 +1,170 @@
+package localcache_test
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leaxoy/localcache"
+)
+
+// zipfScanHitRatio replay a zipfian workload interleaved with scans of cold keys and return the hit ratio.
+func zipfScanHitRatio(policy localcache.EvictionPolicy) float64 {
+	var localCache = localcache.New(localcache.WithMaxEntries(100), localcache.WithEvictionPolicy(policy))
+	defer localCache.Close()
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, 10000)
+	scan := 100000
+	for i := 0; i < 50000; i++ {
+		key := zipf.Uint64()
+		if _, err := localCache.Get(key); err != nil {
+			localCache.Set(key, i)
+		}
+		if i%1000 == 0 {
+			for j := 0; j < 80; j++ {
+				localCache.Set(scan, j)
+				scan++
+			}
+		}
+	}
+	return localCache.Stats().HitRatio()
+}
+
+func TestLocalCache_SLRU(t *testing.T) {
+	var localCache = localcache.New(localcache.WithMaxEntries(4), localcache.WithEvictionPolicy(localcache.PolicySLRU), localcache.WithProtectedRatio(0.5))
+	defer localCache.Close()
+	localCache.Set("hot", 1)
+	localCache.Get("hot")
+	for i := 0; i < 10; i++ {
+		localCache.Set(i, i)
+	}
+	if !localCache.Has("hot") {
+		t.Errorf("err: expect protected key %+v survive the scan\n", "hot")
+	}
+	lru, slru := zipfScanHitRatio(localcache.PolicyLRU), zipfScanHitRatio(localcache.PolicySLRU)
+	if slru <= lru {
+		t.Errorf("err: expect slru hit ratio greater than lru %+v, but got: %+v\n", lru, slru)
+	}
+}
+
+func BenchmarkLocalCache_ZipfLRU(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.ReportMetric(zipfScanHitRatio(localcache.PolicyLRU), "hit-ratio")
+	}
+}
+
+func BenchmarkLocalCache_ZipfSLRU(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.ReportMetric(zipfScanHitRatio(localcache.PolicySLRU), "hit-ratio")
+	}
+}
+
+func TestLocalCache_SoftLimit(t *testing.T) {
+	var evicted int32
+	var localCache = localcache.New(localcache.WithSoftLimit(1), localcache.WithExpireTick(10*time.Millisecond), localcache.WithMaxEntries(1000))
+	defer localCache.Close()
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		if reason == localcache.EvictCapacity {
+			atomic.AddInt32(&evicted, 1)
+		}
+	})
+	for i := 0; i < 100; i++ {
+		localCache.Set(i, i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&evicted) == 0 || localCache.Stats().Entries >= 100 {
+		t.Errorf("err: expect entries evicted over soft limit, but got: %+v\n", localCache.Stats())
+	}
+	if !localCache.Has(99) || localCache.Has(0) {
+		t.Errorf("err: expect least recently used entries evicted first\n")
+	}
+}
+
+func TestLocalCache_SampledLRU(t *testing.T) {
+	clock := newFakeClock()
+	var localCache = localcache.New(localcache.WithShards(1), localcache.WithMaxEntries(100), localcache.WithClock(clock),
+		localcache.WithEvictionPolicy(localcache.PolicySampledLRU), localcache.WithEvictionSampleSize(10))
+	defer localCache.Close()
+	for i := 0; i < 100; i++ {
+		clock.Advance(time.Millisecond)
+		localCache.Set(i, i)
+	}
+	for i := 0; i < 50; i++ {
+		clock.Advance(time.Millisecond)
+		localCache.Get(i)
+	}
+	for i := 100; i < 150; i++ {
+		clock.Advance(time.Millisecond)
+		localCache.Set(i, i)
+	}
+	if n := localCache.Stats().Entries; n != 100 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 100, n)
+	}
+	hot, cold := 0, 0
+	for i := 0; i < 50; i++ {
+		if localCache.Has(i) {
+			hot++
+		}
+		if localCache.Has(i + 50) {
+			cold++
+		}
+	}
+	if hot <= cold {
+		t.Errorf("err: expect roughly oldest entries evicted, but %+v recently used and %+v cold survived\n", hot, cold)
+	}
+}
+
+// benchmarkRead read keys of a full cache with policy in parallel.
+func benchmarkRead(b *testing.B, policy localcache.EvictionPolicy) {
+	var localCache = localcache.New(localcache.WithMaxEntries(1024), localcache.WithEvictionPolicy(policy))
+	defer localCache.Close()
+	for i := 0; i < 1024; i++ {
+		localCache.Set(i, i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			localCache.Get(i & 1023)
+			i++
+		}
+	})
+}
+
+func BenchmarkLocalCache_ReadLRU(b *testing.B) {
+	benchmarkRead(b, localcache.PolicyLRU)
+}
+
+func BenchmarkLocalCache_ReadSampledLRU(b *testing.B) {
+	benchmarkRead(b, localcache.PolicySampledLRU)
+}
+
+func TestLocalCache_TrimToSize(t *testing.T) {
+	var evicted int32
+	var localCache = localcache.New(localcache.WithShards(4), localcache.WithMaxEntries(1000))
+	defer localCache.Close()
+	localCache.OnEvict(func(key localcache.Key, value interface{}, reason localcache.EvictReason) {
+		if reason == localcache.EvictCapacity {
+			atomic.AddInt32(&evicted, 1)
+		}
+	})
+	for i := 0; i < 100; i++ {
+		localCache.Set(i, i)
+	}
+	if n := localCache.TrimToSize(30); n != 70 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 70, n)
+	}
+	if n := localCache.Stats().Entries; n != 30 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 30, n)
+	}
+	if n := atomic.LoadInt32(&evicted); n != 70 {
+		t.Errorf("err: not equal, expect: %+v evictions, but got: %+v\n", 70, n)
+	}
+	if n := localCache.TrimToSize(50); n != 0 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 0, n)
+	}
+	if n := localCache.TrimToSize(0); n != 30 {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", 30, n)
+	}
+}
@@ -0,0 +1,48 @@
+package localcache
+
+import (
+	"context"
+	"time"
+)
+
+// Source is the backing store of a ReadThrough cache.
+type Source interface {
+	// Load return the value of key and how long it should be cached.
+	Load(key Key) (interface{}, time.Duration, error)
+}
+
+// ReadThrough is a LocalCache whose misses are populated from a Source.
+type ReadThrough struct {
+	cache  *LocalCache
+	src    Source
+	errTTL time.Duration
+}
+
+// NewReadThrough return a ReadThrough cache of cache over src.
+func NewReadThrough(cache *LocalCache, src Source) *ReadThrough {
+	return &ReadThrough{cache: cache, src: src}
+}
+
+// CacheErrors cache errors returned by Source for errTTL as GetOrLoad does, so Get within errTTL return
+// the cached error without loading again. It returns r for chaining, errTTL <= 0 means errors will not be cached.
+func (r *ReadThrough) CacheErrors(errTTL time.Duration) *ReadThrough {
+	r.errTTL = errTTL
+	return r
+}
+
+// Cache return the underlying LocalCache.
+func (r *ReadThrough) Cache() *LocalCache {
+	return r.cache
+}
+
+// Get get the value associated by key, or load it from Source and store with the ttl returned by Source
+// if key not exist or has expired. Concurrent loads of the same key share one call of Source.
+func (r *ReadThrough) Get(key Key) (interface{}, error) {
+	return r.cache.compute(context.Background(), key, func(context.Context) (interface{}, time.Duration, error) {
+		v, ttl, err := r.src.Load(key)
+		if err != nil && r.errTTL > 0 {
+			r.cache.SetWithExpire(key, negative{err: err}, r.errTTL)
+		}
+		return v, ttl, err
+	})
+}
@@ -0,0 +1,71 @@
+package localcache_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leaxoy/localcache"
+)
+
+var errSourceDown = errors.New("source down")
+
+// countingSource count loads, block them until gate closed and fail keys other than string.
+type countingSource struct {
+	loads int32
+	gate  chan struct{}
+}
+
+func (s *countingSource) Load(key localcache.Key) (interface{}, time.Duration, error) {
+	atomic.AddInt32(&s.loads, 1)
+	<-s.gate
+	if k, ok := key.(string); ok {
+		return "value of " + k, time.Minute, nil
+	}
+	return nil, 0, errSourceDown
+}
+
+func TestReadThrough_Get(t *testing.T) {
+	var localCache = localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	src := &countingSource{gate: make(chan struct{})}
+	rt := localcache.NewReadThrough(localCache, src)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := rt.Get("xxx"); err != nil || v != "value of xxx" {
+				t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", "value of xxx", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(src.gate)
+	wg.Wait()
+	if n := atomic.LoadInt32(&src.loads); n != 1 {
+		t.Errorf("err: not equal, expect: %+v loads, but got: %+v\n", 1, n)
+	}
+	if ttl, err := localCache.TTL("xxx"); err != nil || ttl > time.Minute || ttl < time.Minute-time.Second {
+		t.Errorf("err: expect value populated with ttl of source, but got: %+v, %+v\n", ttl, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := rt.Get(1); err != errSourceDown {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", errSourceDown, err)
+		}
+	}
+	if n := atomic.LoadInt32(&src.loads); n != 3 || localCache.Has(1) {
+		t.Errorf("err: expect errors not cached, but got: %+v loads\n", n)
+	}
+	rt.CacheErrors(time.Minute)
+	for i := 0; i < 2; i++ {
+		if _, err := rt.Get(2); err != errSourceDown {
+			t.Errorf("err: not equal, expect: %+v, but got: %+v\n", errSourceDown, err)
+		}
+	}
+	if n := atomic.LoadInt32(&src.loads); n != 4 {
+		t.Errorf("err: expect error cached, but got: %+v loads\n", n)
+	}
+}
@@ -0,0 +1,277 @@
+package localcache
+
+import (
+	"container/heap"
+	"container/list"
+	"math"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	offset64 = 14695981039346656037
+	prime64  = 1099511628211
+)
+
+// shard is a part of LocalCache with its own lock, keys are routed to shards by hash.
+type shard struct {
+	mu       sync.RWMutex
+	data     map[Key]Entry
+	expiry   expiryHeap
+	order    *list.List
+	capacity int
+	// protected is the protected segment of PolicySLRU, order is the probationary one.
+	protected    *list.List
+	protectedCap int
+	policy       EvictionPolicy
+	samples      int // the sample size of PolicySampledLRU
+	initial      int // the initial capacity of data
+	tags         map[string]map[Key]struct{}
+	idle         int64
+	clock        Clock
+	size         *int64 // the number of entries of all shards, updated atomically
+	sizer        Sizer
+	bytes        int64     // the approximate bytes of values measured by sizer, updated atomically
+	stats        CacheStat // updated atomically, hits and misses are counted under the read lock
+	pending      []eviction
+	events       []Event
+	hits         keyHits       // the hit counts of keys if TrackKeyHits configured, it has its own lock
+	panics       []interface{} // panics of sizer recovered while holding the lock, reported by unlock
+}
+
+// newShard return a shard hold at most capacity entries, 0 means unlimited.
+// ratio is the ratio of protected segment of PolicySLRU, samples is the sample size of PolicySampledLRU.
+func newShard(capacity int, policy EvictionPolicy, ratio float64, samples int) *shard {
+	s := &shard{data: make(map[Key]Entry), capacity: capacity, policy: policy, clock: realClock{}, size: new(int64)}
+	if policy == PolicySampledLRU {
+		s.samples = samples
+		if s.samples <= 0 {
+			s.samples = defaultSampleSize
+		}
+	}
+	if capacity > 0 && policy != PolicySampledLRU {
+		s.order = list.New()
+		if policy == PolicySLRU {
+			s.protected = list.New()
+			s.protectedCap = protectedCapacity(capacity, ratio)
+		}
+	}
+	return s
+}
+
+// reset remove all entries, the caller must hold the write lock.
+func (s *shard) reset() {
+	atomic.AddInt64(s.size, -int64(len(s.data)))
+	atomic.StoreInt64(&s.bytes, 0)
+	s.data = make(map[Key]Entry, s.initial)
+	s.tags = nil
+	s.expiry = nil
+	if s.order != nil {
+		s.order.Init()
+	}
+	if s.protected != nil {
+		s.protected.Init()
+	}
+}
+
+// search find a not expired entry, the caller must hold the lock.
+func (s *shard) search(key Key) (entry Entry, ok bool) {
+	if entry, ok := s.data[key]; ok {
+		if !entry.IsExpired() {
+			return entry, true
+		}
+	}
+	return
+}
+
+// set store entry of key and keep the expiry heap consistent, the caller must hold the write lock.
+// Metadata of entry will be created if it is a new one.
+func (s *shard) set(key Key, entry Entry) {
+	old, ok := s.data[key]
+	entry.item = nil
+	entry.elem, entry.protected = s.track(key, old)
+	entry.idle = s.idle
+	if s.sizer != nil {
+		entry.bytes = 0
+		if !entry.isNegative() {
+			entry.bytes = s.measure(entry.value)
+		}
+		atomic.AddInt64(&s.bytes, entry.bytes-old.bytes)
+	}
+	if entry.meta == nil {
+		now := s.clock.Now().UnixNano()
+		entry.meta = &entryMeta{created: now, accessed: now, clock: s.clock}
+	}
+	switch {
+	case ok && old.item != nil && entry.expire != 0:
+		old.item.expire = entry.expire
+		heap.Fix(&s.expiry, old.item.index)
+		entry.item = old.item
+	case ok && old.item != nil:
+		heap.Remove(&s.expiry, old.item.index)
+	case entry.expire != 0:
+		entry.item = &expiryItem{key: key, expire: entry.expire}
+		heap.Push(&s.expiry, entry.item)
+	}
+	if !ok {
+		atomic.AddInt64(s.size, 1)
+	}
+	s.untag(key, old.tags)
+	s.tag(key, entry.tags)
+	s.data[key] = entry
+}
+
+// measure return the size of value by sizer, a panic of sizer is recorded and the value is counted as 0 bytes.
+// The caller must hold the write lock.
+func (s *shard) measure(value interface{}) (n int64) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.panics = append(s.panics, r)
+			n = 0
+		}
+	}()
+	return s.sizer(value)
+}
+
+// slide extend the expiration of a live entry by its sliding duration and return the updated entry,
+// negative cached entries never slide. The caller must hold the write lock.
+func (s *shard) slide(key Key, entry Entry) Entry {
+	if entry.sliding <= 0 || entry.isNegative() {
+		return entry
+	}
+	entry.expire = expireAt(s.clock, entry.sliding)
+	s.set(key, entry)
+	return entry
+}
+
+// remove delete entry of key from both data and expiry heap, the caller must hold the write lock.
+func (s *shard) remove(key Key) {
+	if entry, ok := s.data[key]; ok {
+		if entry.item != nil {
+			heap.Remove(&s.expiry, entry.item.index)
+		}
+		if entry.elem != nil {
+			s.list(entry).Remove(entry.elem)
+		}
+		s.untag(key, entry.tags)
+		delete(s.data, key)
+		atomic.AddInt64(s.size, -1)
+		atomic.AddInt64(&s.bytes, -entry.bytes)
+	}
+}
+
+// shardCount round n up to a power of two, at least one.
+func shardCount(n int) int {
+	count := 1
+	for count < n {
+		count <<= 1
+	}
+	return count
+}
+
+// hashKey compute hash of key, string and integer keys have fast path, other keys fallback to hashValue.
+func hashKey(key Key) uint64 {
+	switch k := key.(type) {
+	case string:
+		return hashString(k)
+	case int:
+		return mix(uint64(k))
+	case int8:
+		return mix(uint64(k))
+	case int16:
+		return mix(uint64(k))
+	case int32:
+		return mix(uint64(k))
+	case int64:
+		return mix(uint64(k))
+	case uint:
+		return mix(uint64(k))
+	case uint8:
+		return mix(uint64(k))
+	case uint16:
+		return mix(uint64(k))
+	case uint32:
+		return mix(uint64(k))
+	case uint64:
+		return mix(k)
+	case uintptr:
+		return mix(uint64(k))
+	case NamespacedKey:
+		return hashString(k.Namespace) ^ hashKey(k.Key)
+	default:
+		return hashValue(reflect.ValueOf(k))
+	}
+}
+
+// hashValue compute hash of v consistent with map key equality: pointers and channels are hashed by identity,
+// floats by bits with -0 same as 0, structs and arrays by their fields. Values not comparable go to a fixed hash.
+func hashValue(v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.String:
+		return hashString(v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			return mix(1)
+		}
+		return mix(0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return mix(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return mix(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return hashFloat(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return hashFloat(real(c))*prime64 ^ hashFloat(imag(c))
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return mix(uint64(v.Pointer()))
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return hashValue(v.Elem())
+	case reflect.Struct:
+		var h uint64 = offset64
+		for i := 0; i < v.NumField(); i++ {
+			h = (h ^ hashValue(v.Field(i))) * prime64
+		}
+		return h
+	case reflect.Array:
+		var h uint64 = offset64
+		for i := 0; i < v.Len(); i++ {
+			h = (h ^ hashValue(v.Index(i))) * prime64
+		}
+		return h
+	default:
+		return 0
+	}
+}
+
+// hashFloat hash f by its bits, -0 is hashed same as 0 because they are equal map keys.
+func hashFloat(f float64) uint64 {
+	if f == 0 {
+		f = 0
+	}
+	return mix(math.Float64bits(f))
+}
+
+// hashString is the FNV-1a hash of s.
+func hashString(s string) uint64 {
+	var h uint64 = offset64
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= prime64
+	}
+	return h
+}
+
+// mix is the finalizer of splitmix64, spread integer keys over all bits.
+func mix(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
@@ -0,0 +1,52 @@
+package localcache
+
+import "time"
+
+// tag add key to the index of tags, the caller must hold the write lock.
+func (s *shard) tag(key Key, tags []string) {
+	for _, t := range tags {
+		if s.tags == nil {
+			s.tags = make(map[string]map[Key]struct{})
+		}
+		keys := s.tags[t]
+		if keys == nil {
+			keys = make(map[Key]struct{})
+			s.tags[t] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// untag remove key from the index of tags, the caller must hold the write lock.
+func (s *shard) untag(key Key, tags []string) {
+	for _, t := range tags {
+		delete(s.tags[t], key)
+		if len(s.tags[t]) == 0 {
+			delete(s.tags, t)
+		}
+	}
+}
+
+// SetWithTags set key-value with user setup expiration and associate it with tags, so it can be removed by
+// InvalidateTag of any of them. Tags belong to the stored entry, storing key again without tags drops them.
+func (c *LocalCache) SetWithTags(key Key, value interface{}, duration time.Duration, tags ...string) {
+	entry := c.newEntry(value, duration)
+	entry.tags = append([]string(nil), tags...)
+	c.setEntry(key, entry)
+}
+
+// InvalidateTag remove all entries associated with tag and return how many live entries have been removed.
+// The evicted func is called for them as deleted.
+func (c *LocalCache) InvalidateTag(tag string) int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for key := range s.tags[tag] {
+			if c.delete(s, key) {
+				n++
+			}
+		}
+		c.unlock(s)
+	}
+	return n
+}
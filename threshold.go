@@ -0,0 +1,42 @@
+package localcache
+
+import "sync/atomic"
+
+// threshold is a callback registered by OnThreshold, above is 1 once fired until entries drop below n.
+type threshold struct {
+	n     int
+	fn    func(current int)
+	above int32
+}
+
+// OnThreshold register a callback which will be called once with the number of entries when it reach n,
+// and re-armed when it drop below n again. The number of entries includes expired ones not removed yet,
+// same as Stats().Entries. The callback is called after the lock released by the goroutine which stores
+// the entry, so it must be fast or run its work in another goroutine.
+func (c *LocalCache) OnThreshold(n int, fn func(current int)) {
+	c.mu.Lock()
+	c.thresholds = append(c.thresholds, &threshold{n: n, fn: fn})
+	atomic.StoreInt32(&c.hasThreshold, 1)
+	c.mu.Unlock()
+	c.checkThresholds()
+}
+
+// checkThresholds call callbacks of thresholds which have been reached, the caller must not hold any lock of shards.
+func (c *LocalCache) checkThresholds() {
+	if atomic.LoadInt32(&c.hasThreshold) == 0 {
+		return
+	}
+	current := int(atomic.LoadInt64(&c.size))
+	c.mu.RLock()
+	thresholds := c.thresholds
+	c.mu.RUnlock()
+	for _, t := range thresholds {
+		if current >= t.n {
+			if atomic.CompareAndSwapInt32(&t.above, 0, 1) {
+				c.protect(func() { t.fn(current) })
+			}
+		} else {
+			atomic.StoreInt32(&t.above, 0)
+		}
+	}
+}
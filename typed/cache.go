@@ -0,0 +1,167 @@
+// Package typed provide a generic cache whose keys and values are not boxed into interface{}.
+package typed
+
+import (
+	"sync"
+	"time"
+
+	"github.com/leaxoy/localcache"
+)
+
+// entry is a value with its expire timestamp, 0 means never expire.
+type entry[V any] struct {
+	value  V
+	expire int64
+}
+
+func (e entry[V]) expired(now int64) bool {
+	return e.expire != 0 && e.expire < now
+}
+
+// Cache is an in-memory cache of K-V pairs with expiration, it stores keys and values natively
+// so typed keys are not boxed on Set and Get. It is a lighter sibling of localcache.LocalCache
+// without sharding, capacity limit and persistence. Caches keyed by string or int exclusively, such as
+// Cache[string, V], save the allocation of boxing the key on every Set of LocalCache, and on every Get
+// unless GetStringKeyed or GetIntKeyed of LocalCache is used.
+type Cache[K comparable, V any] struct {
+	mu         sync.RWMutex
+	data       map[K]entry[V]
+	expiration time.Duration
+	evicted    func(key K, value V)
+	done       chan struct{}
+	closeOnce  sync.Once
+	sweeper    sync.WaitGroup
+}
+
+// New return an empty Cache, entries set by Set expire after expiration and expired entries are
+// removed every tick. expiration <= 0 means never expire, tick <= 0 means no background sweep.
+func New[K comparable, V any](expiration, tick time.Duration) *Cache[K, V] {
+	c := &Cache[K, V]{
+		data:       make(map[K]entry[V]),
+		expiration: expiration,
+		done:       make(chan struct{}),
+	}
+	if tick > 0 {
+		c.sweeper.Add(1)
+		go c.expireLoop(tick)
+	}
+	return c
+}
+
+// Close stop the background sweep, it is safe to call Close many times.
+func (c *Cache[K, V]) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.sweeper.Wait()
+	})
+	return nil
+}
+
+func (c *Cache[K, V]) expireLoop(tick time.Duration) {
+	defer c.sweeper.Done()
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			c.expireKeys()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// expireKeys remove all expired entries, the evicted func is called after the lock released.
+func (c *Cache[K, V]) expireKeys() {
+	var evicted []K
+	var values []V
+	c.mu.Lock()
+	now := time.Now().UnixNano()
+	for key, e := range c.data {
+		if e.expired(now) {
+			delete(c.data, key)
+			if c.evicted != nil {
+				evicted = append(evicted, key)
+				values = append(values, e.value)
+			}
+		}
+	}
+	fn := c.evicted
+	c.mu.Unlock()
+	for i, key := range evicted {
+		fn(key, values[i])
+	}
+}
+
+// SetEvictedFunc set the func called when an entry is removed by expiration or Delete.
+func (c *Cache[K, V]) SetEvictedFunc(fn func(key K, value V)) {
+	c.mu.Lock()
+	c.evicted = fn
+	c.mu.Unlock()
+}
+
+// Set set key-value with default expiration.
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithExpire(key, value, c.expiration)
+}
+
+// SetWithExpire set key-value with user setup expiration, duration <= 0 means never expire.
+func (c *Cache[K, V]) SetWithExpire(key K, value V, duration time.Duration) {
+	e := entry[V]{value: value}
+	if duration > 0 {
+		e.expire = time.Now().Add(duration).UnixNano()
+	}
+	c.mu.Lock()
+	c.data[key] = e
+	c.mu.Unlock()
+}
+
+// Add will do same as Set but return localcache.ErrDuplicateKey if key exists.
+func (c *Cache[K, V]) Add(key K, value V) error {
+	e := entry[V]{value: value}
+	if c.expiration > 0 {
+		e.expire = time.Now().Add(c.expiration).UnixNano()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.data[key]; ok && !old.expired(time.Now().UnixNano()) {
+		return localcache.ErrDuplicateKey
+	}
+	c.data[key] = e
+	return nil
+}
+
+// Get get the value associated by key, or localcache.ErrNoSuchKey and localcache.ErrExpiredKey.
+// Expired entries are left to the background sweep.
+func (c *Cache[K, V]) Get(key K) (v V, err error) {
+	c.mu.RLock()
+	e, ok := c.data[key]
+	c.mu.RUnlock()
+	if !ok {
+		return v, localcache.ErrNoSuchKey
+	}
+	if e.expired(time.Now().UnixNano()) {
+		return v, localcache.ErrExpiredKey
+	}
+	return e.value, nil
+}
+
+// Delete remove key and report whether it has been removed.
+func (c *Cache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	e, ok := c.data[key]
+	delete(c.data, key)
+	fn := c.evicted
+	c.mu.Unlock()
+	if ok && fn != nil {
+		fn(key, e.value)
+	}
+	return ok
+}
+
+// Len return the number of entries, including expired ones not removed yet.
+func (c *Cache[K, V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.data)
+}
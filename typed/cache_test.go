@@ -0,0 +1,112 @@
+package typed_test
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leaxoy/localcache"
+	"github.com/leaxoy/localcache/typed"
+)
+
+func TestCache(t *testing.T) {
+	var cache = typed.New[int, string](time.Minute, 10*time.Millisecond)
+	defer cache.Close()
+	var evicted int32
+	cache.SetEvictedFunc(func(key int, value string) {
+		atomic.AddInt32(&evicted, 1)
+	})
+	cache.Set(1, "one")
+	if v, err := cache.Get(1); err != nil || v != "one" {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v, %+v\n", "one", v, err)
+	}
+	if err := cache.Add(1, "uno"); err != localcache.ErrDuplicateKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrDuplicateKey, err)
+	}
+	if _, err := cache.Get(2); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", localcache.ErrNoSuchKey, err)
+	}
+	cache.SetWithExpire(3, "three", time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if _, err := cache.Get(3); err != localcache.ErrNoSuchKey {
+		t.Errorf("err: expect expired key swept, but got: %+v\n", err)
+	}
+	if !cache.Delete(1) || cache.Len() != 0 {
+		t.Errorf("err: expect cache empty after delete, but got len: %+v\n", cache.Len())
+	}
+	if n := atomic.LoadInt32(&evicted); n != 2 {
+		t.Errorf("err: not equal, expect: %+v evictions, but got: %+v\n", 2, n)
+	}
+}
+
+func BenchmarkCache_Set(b *testing.B) {
+	cache := typed.New[int, int](time.Minute, time.Minute)
+	defer cache.Close()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cache.Set(i&1023+1024, i)
+	}
+}
+
+func BenchmarkLocalCache_SetIntKey(b *testing.B) {
+	localCache := localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		localCache.Set(i&1023+1024, i)
+	}
+}
+
+// stringKeys is the keys of string keyed benchmarks, built ahead so only the cache is measured.
+var stringKeys = func() []string {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key:" + strconv.Itoa(i)
+	}
+	return keys
+}()
+
+func BenchmarkCache_SetStringKey(b *testing.B) {
+	cache := typed.New[string, int](time.Minute, time.Minute)
+	defer cache.Close()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cache.Set(stringKeys[i&1023], i)
+	}
+}
+
+func BenchmarkLocalCache_SetStringKey(b *testing.B) {
+	localCache := localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		localCache.Set(stringKeys[i&1023], i)
+	}
+}
+
+func BenchmarkCache_GetStringKey(b *testing.B) {
+	cache := typed.New[string, int](time.Minute, time.Minute)
+	defer cache.Close()
+	for i, key := range stringKeys {
+		cache.Set(key, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Get(stringKeys[i&1023])
+	}
+}
+
+func BenchmarkLocalCache_GetStringKey(b *testing.B) {
+	localCache := localcache.NewLocalCache(nil)
+	defer localCache.Close()
+	for i, key := range stringKeys {
+		localCache.Set(key, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		localCache.Get(stringKeys[i&1023])
+	}
+}
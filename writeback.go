@@ -0,0 +1,84 @@
+package localcache
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultWriteBackInterval = time.Second
+
+// Sink receive the dirty entries of a write-back cache, keys are kept dirty and retried later if it fails.
+type Sink func(items map[Key]interface{}) error
+
+// dirtySet is the keys whose values have been stored but not flushed to Sink yet.
+type dirtySet struct {
+	mu   sync.Mutex
+	keys map[Key]struct{}
+}
+
+// mark add key to the set.
+func (d *dirtySet) mark(key Key) {
+	d.mu.Lock()
+	if d.keys == nil {
+		d.keys = make(map[Key]struct{})
+	}
+	d.keys[key] = struct{}{}
+	d.mu.Unlock()
+}
+
+// take remove at most n keys from the set and return them, n <= 0 means all.
+func (d *dirtySet) take(n int) []Key {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	keys := make([]Key, 0, len(d.keys))
+	for key := range d.keys {
+		if n > 0 && len(keys) >= n {
+			break
+		}
+		keys = append(keys, key)
+		delete(d.keys, key)
+	}
+	return keys
+}
+
+func (c *LocalCache) writeBackLoop(interval time.Duration) {
+	defer c.sweeper.Done()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			c.flushDirty(c.config.WriteBackBatch)
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// flushDirty pass the live values of at most n dirty keys to Sink, n <= 0 means all.
+// Keys deleted or expired since stored are skipped, and keys are marked dirty again if Sink fails or panics,
+// the panic is reported to OnCallbackError.
+func (c *LocalCache) flushDirty(n int) {
+	keys := c.dirty.take(n)
+	if len(keys) == 0 {
+		return
+	}
+	items := make(map[Key]interface{}, len(keys))
+	for _, key := range keys {
+		if e, ok := c.live(key); ok && !e.isNegative() {
+			items[key] = e.value
+		}
+	}
+	if len(items) == 0 {
+		return
+	}
+	failed := true
+	c.protect(func() {
+		failed = c.config.WriteBack(items) != nil
+	})
+	if failed {
+		for key := range items {
+			c.dirty.mark(key)
+		}
+	}
+}
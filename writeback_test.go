@@ -0,0 +1,81 @@
+package localcache_test
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leaxoy/localcache"
+)
+
+func TestLocalCache_WriteBack(t *testing.T) {
+	var mu sync.Mutex
+	flushed := make(map[localcache.Key]interface{})
+	fail := true
+	sink := func(items map[localcache.Key]interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			fail = false
+			return errors.New("store unavailable")
+		}
+		for key, value := range items {
+			flushed[key] = value
+		}
+		return nil
+	}
+	var localCache = localcache.New(localcache.WithWriteBack(sink, 10*time.Millisecond, 0))
+	localCache.Set("a", 1)
+	localCache.Set("b", 2)
+	localCache.Set("c", 3)
+	localCache.Delete("c")
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	expect := map[localcache.Key]interface{}{"a": 1, "b": 2}
+	if !reflect.DeepEqual(flushed, expect) {
+		t.Errorf("err: not equal, expect: %+v, but got: %+v\n", expect, flushed)
+	}
+	mu.Unlock()
+	localCache.Set("a", 10)
+	localCache.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if flushed["a"] != 10 {
+		t.Errorf("err: expect dirty key flushed by Close, but got: %+v\n", flushed["a"])
+	}
+}
+
+func TestLocalCache_WriteBackPanic(t *testing.T) {
+	var mu sync.Mutex
+	flushed := make(map[localcache.Key]interface{})
+	calls := 0
+	sink := func(items map[localcache.Key]interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			panic("store crashed")
+		}
+		for key, value := range items {
+			flushed[key] = value
+		}
+		return nil
+	}
+	var localCache = localcache.New(localcache.WithWriteBack(sink, 10*time.Millisecond, 0))
+	var recovered []interface{}
+	localCache.OnCallbackError(func(r interface{}) {
+		mu.Lock()
+		recovered = append(recovered, r)
+		mu.Unlock()
+	})
+	localCache.Set("a", 1)
+	time.Sleep(50 * time.Millisecond)
+	localCache.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(recovered, []interface{}{"store crashed"}) || flushed["a"] != 1 {
+		t.Errorf("err: expect panic recovered and key flushed again, but got: %+v, %+v\n", recovered, flushed)
+	}
+}